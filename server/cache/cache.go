@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -24,10 +25,52 @@ type Cache interface {
 
 	GetStats(ctx context.Context) (*CacheStats, error)
 
+	// Lock acquires a distributed lock on key for ttl, returning a handle
+	// that refreshes its own lease in the background until Unlock is
+	// called or the refresh is lost. Returns ErrLockHeld if key is already
+	// locked by someone else.
+	Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+
 	Close() error
 }
 
+// Lock is the handle returned by Cache.Lock.
+type Lock interface {
+	// Unlock releases the lock. The background refresher is always
+	// stopped, even if the remote release fails - a leaked remote key is
+	// an operator's problem either way, but there's no reason to also
+	// leave this process's refresh goroutine running against it.
+	Unlock(ctx context.Context) error
+
+	// Done is closed if the lock's lease is lost - refresh failed, or
+	// another holder took over - before Unlock was called. Callers doing
+	// long-running work under the lock should select on it and abort.
+	Done() <-chan struct{}
+}
+
+// ErrLockHeld is returned by Cache.Lock when key is already locked by
+// another holder.
+var ErrLockHeld = errors.New("lock held by another holder")
+
+// lockKeyPrefix namespaces Lock's keys away from ordinary Set/Get entries.
+// Without it, a cache populated at the same key a caller locks could make
+// Lock spuriously report ErrLockHeld (or be clobbered by an unrelated
+// Set) - callers shouldn't have to know to prefix their own lock keys for
+// Lock to be safe to use alongside the rest of the Cache interface.
+const lockKeyPrefix = "lock:"
+
+// ErrLockLost is returned by Lock.Unlock when the lock had already been
+// lost to another holder (lease expired and was reacquired elsewhere)
+// before Unlock was called.
+var ErrLockLost = errors.New("lock was lost before unlock")
+
 type CacheStats struct {
 	Connected bool   `json:"connected"`
 	Info      string `json:"info"`
+
+	// Populated by TieredCache; zero for single-tier backends.
+	L1Hits        int64 `json:"l1_hits,omitempty"`
+	L2Hits        int64 `json:"l2_hits,omitempty"`
+	Misses        int64 `json:"misses,omitempty"`
+	Invalidations int64 `json:"invalidations,omitempty"`
 }
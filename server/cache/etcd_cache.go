@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+// EtcdCache implements Cache on top of go.etcd.io/etcd/client/v3, giving
+// operators a strongly-consistent alternative to Redis for small shared
+// state (auth revocations, rate-limit counters, feature flags) backed by
+// etcd leases for TTL and STM transactions for atomic counters.
+type EtcdCache struct {
+	client *clientv3.Client
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// NewEtcdCache connects to the etcd cluster at endpoints and confirms
+// reachability via Status before returning, so connection failures surface
+// at startup rather than on the first Set/Get.
+func NewEtcdCache(endpoints []string, ttl time.Duration, logger *zap.Logger) (*EtcdCache, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Status(pingCtx, endpoints[0]); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+
+	return &EtcdCache{client: client, ttl: ttl, logger: logger}, nil
+}
+
+func (c *EtcdCache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, c.ttl)
+}
+
+func (c *EtcdCache) Get(ctx context.Context, key string, dest interface{}) error {
+	resp, err := c.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return ErrCacheMiss
+	}
+
+	return json.Unmarshal(resp.Kvs[0].Value, dest)
+}
+
+func (c *EtcdCache) Delete(ctx context.Context, key string) error {
+	_, err := c.client.Delete(ctx, key)
+	return err
+}
+
+func (c *EtcdCache) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := c.client.Get(ctx, key, clientv3.WithCountOnly())
+	if err != nil {
+		return false, err
+	}
+	return resp.Count > 0, nil
+}
+
+// SetWithTTL grants a lease for ttl and writes key under it, so the key
+// expires on etcd's side without this process having to clean it up.
+func (c *EtcdCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache value: %w", err)
+	}
+
+	lease, err := c.client.Grant(ctx, int64(ttl.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to grant lease: %w", err)
+	}
+
+	_, err = c.client.Put(ctx, key, string(data), clientv3.WithLease(lease.ID))
+	return err
+}
+
+// GetTTL reads the lease attached to key's current value and asks etcd how
+// much of it is left. A key with no lease (set via Set/SetWithTTL only
+// ever attaches one, but a key written by some other tool might not) is
+// treated as having nothing meaningful to return, same as RedisCache.GetTTL.
+func (c *EtcdCache) GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	resp, err := c.client.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, ErrCacheMiss
+	}
+
+	leaseID := resp.Kvs[0].Lease
+	if leaseID == 0 {
+		return 0, ErrCacheMiss
+	}
+
+	ttlResp, err := c.client.TimeToLive(ctx, clientv3.LeaseID(leaseID))
+	if err != nil {
+		return 0, err
+	}
+	if ttlResp.TTL < 0 {
+		return 0, ErrCacheMiss
+	}
+
+	return time.Duration(ttlResp.TTL) * time.Second, nil
+}
+
+func (c *EtcdCache) Increment(ctx context.Context, key string) (int64, error) {
+	return c.IncrementWithTTL(ctx, key, c.ttl)
+}
+
+// IncrementWithTTL reads, bumps, and writes the counter at key inside an
+// STM transaction, so concurrent incrementers across replicas can't race
+// the read-modify-write the way CacheRateLimiter's generic
+// Cache-interface algorithms have to. The lease backing ttl is granted
+// outside the transaction (lease operations aren't part of etcd's
+// transaction API) and attached to the STM's Put.
+func (c *EtcdCache) IncrementWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	lease, err := c.client.Grant(ctx, int64(ttl.Seconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to grant lease: %w", err)
+	}
+
+	var result int64
+	_, err = concurrency.NewSTM(c.client, func(stm concurrency.STM) error {
+		n := int64(0)
+		if current := stm.Get(key); current != "" {
+			parsed, err := strconv.ParseInt(current, 10, 64)
+			if err != nil {
+				return fmt.Errorf("corrupt counter value %q at key %q: %w", current, key, err)
+			}
+			n = parsed
+		}
+
+		n++
+		result = n
+		stm.Put(key, strconv.FormatInt(n, 10), clientv3.WithLease(lease.ID))
+		return nil
+	}, concurrency.WithAbortContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// GetStats reports the status of the first configured endpoint via the
+// client's Maintenance Status call.
+func (c *EtcdCache) GetStats(ctx context.Context) (*CacheStats, error) {
+	endpoints := c.client.Endpoints()
+	if len(endpoints) == 0 {
+		return &CacheStats{Connected: false, Info: "no endpoints configured"}, nil
+	}
+
+	status, err := c.client.Status(ctx, endpoints[0])
+	if err != nil {
+		return &CacheStats{Connected: false, Info: err.Error()}, nil
+	}
+
+	return &CacheStats{
+		Connected: true,
+		Info: fmt.Sprintf("endpoint=%s,version=%s,dbSize=%d,leader=%x",
+			endpoints[0], status.Version, status.DbSize, status.Leader),
+	}, nil
+}
+
+// Lock acquires a distributed lock on key using etcd's own concurrency
+// primitives: a lease-backed Session (which keeps itself alive in the
+// background, closing its Done channel if that keepalive ever fails) and
+// a Mutex over it. This is etcd's idiomatic lock pattern, so it's used
+// directly here rather than reimplementing RedisCache's
+// SET-NX-plus-CAS-script approach on top of raw KV operations.
+func (c *EtcdCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	session, err := concurrency.NewSession(c.client, concurrency.WithTTL(int(ttl.Seconds())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock session: %w", err)
+	}
+
+	mutex := concurrency.NewMutex(session, "/locks/"+key)
+	if err := mutex.TryLock(ctx); err != nil {
+		session.Close()
+		if err == concurrency.ErrLocked {
+			return nil, ErrLockHeld
+		}
+		return nil, err
+	}
+
+	return &etcdLock{session: session, mutex: mutex}, nil
+}
+
+type etcdLock struct {
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+}
+
+func (l *etcdLock) Done() <-chan struct{} {
+	return l.session.Done()
+}
+
+func (l *etcdLock) Unlock(ctx context.Context) error {
+	err := l.mutex.Unlock(ctx)
+	// The session's own keepalive lease is what actually holds the lock
+	// open; closing it releases the key even if Unlock above failed, so
+	// this process is never left refreshing a lock it thinks it released.
+	if closeErr := l.session.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *EtcdCache) Close() error {
+	return c.client.Close()
+}
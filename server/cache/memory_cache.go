@@ -83,9 +83,14 @@ func (c *MemoryCache) Get(ctx context.Context, key string, dest interface{}) err
 
 	if destPtr, ok := dest.(*interface{}); ok {
 		*destPtr = item.Value
+		return nil
 	}
 
-	return nil
+	// dest is a concrete pointer (e.g. *models.AnalysisResult) rather than
+	// *interface{} - round-trip item.Value through JSON into it the same
+	// way TieredCache does (both use decodeInto), since Value was stored as whatever
+	// concrete type Set was called with.
+	return decodeInto(item.Value, dest)
 }
 
 func (c *MemoryCache) Delete(ctx context.Context, key string) error {
@@ -232,6 +237,126 @@ func (c *MemoryCache) GetStats(ctx context.Context) (*CacheStats, error) {
 	return stats, nil
 }
 
+// Lock acquires an in-process lock on key, storing a random token as the
+// item's value and treating an existing, unexpired item at key as already
+// held. A background goroutine extends the item's expiry every ttl/3 via
+// a token-checked compare-and-swap, the single-process equivalent of
+// RedisCache.Lock's CAS-guarded PEXPIRE.
+//
+// key is stored under the lockKeyPrefix namespace, not key itself, so an
+// unrelated Set/Get entry at key can never collide with a lock and trip a
+// spurious ErrLockHeld.
+func (c *MemoryCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	key = lockKeyPrefix + key
+
+	token, err := randomLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	if item, exists := c.items[key]; exists && time.Now().Before(item.ExpiresAt) {
+		c.mutex.Unlock()
+		return nil, ErrLockHeld
+	}
+	c.items[key] = &CacheItem{
+		Value:       token,
+		ExpiresAt:   time.Now().Add(ttl),
+		LastUsed:    time.Now(),
+		AccessCount: 1,
+	}
+	c.mutex.Unlock()
+
+	l := &memoryLock{
+		cache:  c,
+		key:    key,
+		token:  token,
+		ttl:    ttl,
+		done:   make(chan struct{}),
+		stopCh: make(chan struct{}),
+	}
+	go l.refreshLoop()
+
+	return l, nil
+}
+
+// casExtend extends key's expiry by ttl only if it still holds token,
+// returning false if the key was released, reassigned, or already expired
+// out from under this holder.
+func (c *MemoryCache) casExtend(key, token string, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item, exists := c.items[key]
+	if !exists || item.Value != token || time.Now().After(item.ExpiresAt) {
+		return false
+	}
+
+	item.ExpiresAt = time.Now().Add(ttl)
+	return true
+}
+
+// casDelete deletes key only if it still holds token, for the same reason
+// casExtend only extends under that condition.
+func (c *MemoryCache) casDelete(key, token string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item, exists := c.items[key]
+	if !exists || item.Value != token {
+		return false
+	}
+
+	delete(c.items, key)
+	return true
+}
+
+type memoryLock struct {
+	cache *MemoryCache
+	key   string
+	token string
+	ttl   time.Duration
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *memoryLock) refreshLoop() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !l.cache.casExtend(l.key, l.token, l.ttl) {
+				l.closeDone()
+				return
+			}
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+func (l *memoryLock) closeDone() {
+	l.doneOnce.Do(func() { close(l.done) })
+}
+
+func (l *memoryLock) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *memoryLock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+
+	if !l.cache.casDelete(l.key, l.token) {
+		return ErrLockLost
+	}
+	return nil
+}
+
 func (c *MemoryCache) Close() error {
 	if c.cleanup != nil {
 		c.cleanup.Stop()
@@ -2,223 +2,425 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
 
+// RedisOptions configures how NewRedisCacheWithOptions connects: a plain
+// single node, a Sentinel-monitored primary, or a Cluster, optionally over
+// TLS. At most one of Sentinel/Cluster should be set; a single-node
+// connection to Host:Port is used when neither is.
+type RedisOptions struct {
+	Host     string
+	Port     int
+	Password string
+	DB       int
+	PoolSize int
+
+	TLS *tls.Config
+
+	Sentinel *SentinelOptions
+	Cluster  *ClusterOptions
+}
+
+// SentinelOptions selects a Sentinel-monitored primary by name, following
+// a set of Sentinel addresses to find it.
+type SentinelOptions struct {
+	MasterName string
+	Addrs      []string
+}
+
+// ClusterOptions lists seed addresses for a Redis Cluster deployment.
+type ClusterOptions struct {
+	Addrs []string
+}
+
+// redisClient is the subset of *redis.Client / *redis.ClusterClient that
+// RedisCache needs. NewRedisCacheWithOptions picks whichever concrete type
+// fits RedisOptions; everything downstream only depends on this interface,
+// so Set/Get/etc. don't need a topology-specific code path.
+type redisClient interface {
+	redis.Cmdable
+	Close() error
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
 type RedisCache struct {
-	items   map[string]*CacheItem
-	mutex   sync.RWMutex
-	ttl     time.Duration
-	logger  *zap.Logger
-	cleanup *time.Ticker
-	stopCh  chan struct{}
+	client redisClient
+	ttl    time.Duration
+	logger *zap.Logger
 }
 
+// NewRedisCache connects to a single-node Redis instance at host:port. For
+// TLS, Sentinel, or Cluster deployments use NewRedisCacheWithOptions.
 func NewRedisCache(host string, port int, password string, db int, ttl time.Duration, logger *zap.Logger) (*RedisCache, error) {
-	logger.Warn("Using memory cache instead of Redis",
-		zap.String("host", host),
-		zap.Int("port", port))
+	return NewRedisCacheWithOptions(RedisOptions{
+		Host:     host,
+		Port:     port,
+		Password: password,
+		DB:       db,
+	}, ttl, logger)
+}
 
-	cache := &RedisCache{
-		items:  make(map[string]*CacheItem),
-		ttl:    ttl,
-		logger: logger,
-		stopCh: make(chan struct{}),
+// NewRedisCacheWithOptions connects according to opts - single node,
+// Sentinel, or Cluster - and pings the result before returning, so
+// connection failures surface at startup rather than on the first Set/Get.
+func NewRedisCacheWithOptions(opts RedisOptions, ttl time.Duration, logger *zap.Logger) (*RedisCache, error) {
+	var client redisClient
+	switch {
+	case opts.Cluster != nil:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     opts.Cluster.Addrs,
+			Password:  opts.Password,
+			PoolSize:  opts.PoolSize,
+			TLSConfig: opts.TLS,
+		})
+	case opts.Sentinel != nil:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    opts.Sentinel.MasterName,
+			SentinelAddrs: opts.Sentinel.Addrs,
+			Password:      opts.Password,
+			DB:            opts.DB,
+			PoolSize:      opts.PoolSize,
+			TLSConfig:     opts.TLS,
+		})
+	default:
+		client = redis.NewClient(&redis.Options{
+			Addr:      fmt.Sprintf("%s:%d", opts.Host, opts.Port),
+			Password:  opts.Password,
+			DB:        opts.DB,
+			PoolSize:  opts.PoolSize,
+			TLSConfig: opts.TLS,
+		})
 	}
 
-	cache.cleanup = time.NewTicker(1 * time.Minute)
-	go cache.cleanupExpired()
+	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
 
-	return cache, nil
+	return &RedisCache{client: client, ttl: ttl, logger: logger}, nil
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	c.items[key] = &CacheItem{
-		Value:     value,
-		ExpiresAt: time.Now().Add(c.ttl),
-	}
-
-	return nil
+	return c.SetWithTTL(ctx, key, value, c.ttl)
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
-	c.mutex.RLock()
-	item, exists := c.items[key]
-	c.mutex.RUnlock()
-
-	if !exists {
-		return ErrCacheMiss
-	}
-
-	if time.Now().After(item.ExpiresAt) {
-		c.mutex.Lock()
-		delete(c.items, key)
-		c.mutex.Unlock()
-		return ErrCacheMiss
-	}
-
-	if destPtr, ok := dest.(*interface{}); ok {
-		*destPtr = item.Value
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrCacheMiss
+		}
+		return err
 	}
 
-	return nil
+	return json.Unmarshal(data, dest)
 }
 
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	delete(c.items, key)
-	return nil
+	return c.client.Del(ctx, key).Err()
 }
 
 func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-
-	item, exists := c.items[key]
-	if !exists {
-		return false, nil
+	n, err := c.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
 	}
-
-	if time.Now().After(item.ExpiresAt) {
-		return false, nil
-	}
-
-	return true, nil
+	return n > 0, nil
 }
 
 func (c *RedisCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	c.items[key] = &CacheItem{
-		Value:     value,
-		ExpiresAt: time.Now().Add(ttl),
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache value: %w", err)
 	}
-
-	return nil
+	return c.client.Set(ctx, key, data, ttl).Err()
 }
 
 func (c *RedisCache) GetTTL(ctx context.Context, key string) (time.Duration, error) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-
-	item, exists := c.items[key]
-	if !exists {
-		return 0, ErrCacheMiss
+	ttl, err := c.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
 	}
 
-	if time.Now().After(item.ExpiresAt) {
+	// go-redis reports no TTL as -1 (key exists, no expiry) or -2 (key
+	// doesn't exist); either way there's nothing meaningful to return.
+	if ttl < 0 {
 		return 0, ErrCacheMiss
 	}
 
-	return time.Until(item.ExpiresAt), nil
+	return ttl, nil
 }
+
 func (c *RedisCache) Increment(ctx context.Context, key string) (int64, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	item, exists := c.items[key]
-	if !exists {
-		c.items[key] = &CacheItem{
-			Value:     int64(1),
-			ExpiresAt: time.Now().Add(c.ttl),
-		}
-		return 1, nil
-	}
+	return c.IncrementWithTTL(ctx, key, c.ttl)
+}
 
-	if time.Now().After(item.ExpiresAt) {
-		item.Value = int64(1)
-		item.ExpiresAt = time.Now().Add(c.ttl)
-		return 1, nil
-	}
+// IncrementWithTTL increments key and (re)sets its TTL in one pipeline, so
+// a crash between the two calls can't leave a counter with no expiry.
+func (c *RedisCache) IncrementWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
 
-	if count, ok := item.Value.(int64); ok {
-		item.Value = count + 1
-		return item.Value.(int64), nil
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
 	}
 
-	item.Value = int64(1)
-	return 1, nil
+	return incr.Val(), nil
 }
 
-func (c *RedisCache) IncrementWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	item, exists := c.items[key]
-	if !exists {
-		c.items[key] = &CacheItem{
-			Value:     int64(1),
-			ExpiresAt: time.Now().Add(ttl),
-		}
-		return 1, nil
+func (c *RedisCache) GetStats(ctx context.Context) (*CacheStats, error) {
+	info, err := c.client.Info(ctx, "server", "clients", "memory", "stats").Result()
+	if err != nil {
+		return &CacheStats{Connected: false, Info: err.Error()}, nil
 	}
 
-	if count, ok := item.Value.(int64); ok {
-		item.Value = count + 1
-	} else {
-		item.Value = int64(1)
+	dbSize, err := c.client.DBSize(ctx).Result()
+	if err != nil {
+		c.logger.Warn("Failed to get redis DBSIZE", zap.Error(err))
+		dbSize = -1
 	}
-	item.ExpiresAt = time.Now().Add(ttl)
 
-	return item.Value.(int64), nil
+	return &CacheStats{
+		Connected: true,
+		Info:      fmt.Sprintf("keys=%d\n%s", dbSize, info),
+	}, nil
 }
 
-func (c *RedisCache) GetStats(ctx context.Context) (*CacheStats, error) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+// invalidationChannel is the pub/sub channel PublishInvalidation/
+// SubscribeInvalidations use to tell every process sharing this Redis
+// instance as an L2 to drop a key from its local L1 (see TieredCache).
+const invalidationChannel = "motorcycle-cv:cache:invalidate"
 
-	now := time.Now()
-	expiredCount := 0
+// PublishInvalidation implements cache.Invalidator, broadcasting key to
+// every subscriber of invalidationChannel - normally other processes'
+// TieredCache L1s.
+func (c *RedisCache) PublishInvalidation(ctx context.Context, key string) error {
+	return c.client.Publish(ctx, invalidationChannel, key).Err()
+}
 
-	for _, item := range c.items {
-		if now.After(item.ExpiresAt) {
-			expiredCount++
+// SubscribeInvalidations implements cache.Invalidator. The returned channel
+// closes when the subscription's context is cancelled or the connection is
+// lost; the returned close func releases the underlying subscription early.
+func (c *RedisCache) SubscribeInvalidations(ctx context.Context) (<-chan string, func() error) {
+	pubsub := c.client.Subscribe(ctx, invalidationChannel)
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for msg := range pubsub.Channel() {
+			out <- msg.Payload
 		}
+	}()
+
+	return out, pubsub.Close
+}
+
+// tokenBucketScript atomically refills and decrements a token-bucket hash
+// {tokens, last_update} stored at KEYS[1], so EvalTokenBucket stays
+// correct across concurrent callers on different replicas instead of
+// racing a separate read, refill, and write.
+var tokenBucketScript = redis.NewScript(`
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+local lastUpdate = tonumber(redis.call("HGET", KEYS[1], "last_update"))
+local rps = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+if tokens == nil then
+	tokens = burst
+	lastUpdate = now
+end
+
+local elapsed = (now - lastUpdate) / 1e9
+local refill = math.floor(elapsed * rps)
+tokens = math.min(burst, tokens + refill)
+
+local retryAfterMs = 0
+if tokens <= 0 then
+	retryAfterMs = math.floor(1000 / rps)
+else
+	tokens = tokens - 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "last_update", now)
+redis.call("EXPIRE", KEYS[1], 3600)
+
+return {tokens, retryAfterMs}
+`)
+
+// EvalTokenBucket runs tokenBucketScript for an atomic token-bucket
+// check-and-decrement, replacing the earlier in-memory-map placeholder
+// (which was only atomic within one process, not across replicas). It
+// returns the token count after the request (0 if rejected) and how many
+// milliseconds the caller should wait before retrying.
+func (c *RedisCache) EvalTokenBucket(ctx context.Context, key string, rps, burst int) (tokens int64, retryAfterMs int64, err error) {
+	result, err := tokenBucketScript.Run(ctx, c.client, []string{key}, rps, burst, time.Now().UnixNano()).Result()
+	if err != nil {
+		return 0, 0, err
 	}
 
-	stats := &CacheStats{
-		Connected: true,
-		Info: fmt.Sprintf("items=%d,expired=%d,ttl=%v",
-			len(c.items), expiredCount, c.ttl),
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("unexpected token bucket script result: %v", result)
 	}
 
-	return stats, nil
+	tokens, _ = values[0].(int64)
+	retryAfterMs, _ = values[1].(int64)
+	return tokens, retryAfterMs, nil
 }
 
 func (c *RedisCache) Close() error {
-	if c.cleanup != nil {
-		c.cleanup.Stop()
+	return c.client.Close()
+}
+
+var ErrCacheMiss = fmt.Errorf("cache miss")
+
+// lockReleaseScript deletes KEYS[1] only if it still holds ARGV[1], so a
+// holder whose lease already expired and was reacquired by someone else
+// can't delete the new holder's lock out from under them.
+var lockReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end
+`)
+
+// lockRefreshScript extends KEYS[1]'s expiry to ARGV[2] milliseconds only
+// if it still holds ARGV[1] - the same CAS-compare guard as
+// lockReleaseScript, applied to PEXPIRE instead of DEL.
+var lockRefreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end
+`)
+
+// randomLockToken generates the random value a Lock holder writes into
+// its key, so release/refresh can tell "I still hold this" from "someone
+// else's lease expired and they reacquired it" via a CAS compare.
+func randomLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
-	close(c.stopCh)
-	return nil
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Lock acquires a distributed lock on key via SET key token NX PX ttl,
+// starting a background goroutine that refreshes the lease every ttl/3 via
+// a CAS-guarded PEXPIRE so a long-held lock doesn't expire out from under
+// a slow holder.
+//
+// key is namespaced under lockKeyPrefix before it ever reaches Redis, so
+// an unrelated Set/Get entry at key can't collide with a lock.
+func (c *RedisCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	key = lockKeyPrefix + key
+
+	token, err := randomLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := c.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+
+	l := &redisLock{
+		client: c.client,
+		key:    key,
+		token:  token,
+		ttl:    ttl,
+		logger: c.logger,
+		done:   make(chan struct{}),
+		stopCh: make(chan struct{}),
+	}
+	go l.refreshLoop()
+
+	return l, nil
 }
 
-func (c *RedisCache) cleanupExpired() {
+type redisLock struct {
+	client redisClient
+	key    string
+	token  string
+	ttl    time.Duration
+	logger *zap.Logger
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *redisLock) refreshLoop() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-c.cleanup.C:
-			c.mutex.Lock()
-			now := time.Now()
-			for key, item := range c.items {
-				if now.After(item.ExpiresAt) {
-					delete(c.items, key)
-				}
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+			result, err := lockRefreshScript.Run(ctx, l.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int64()
+			cancel()
+
+			if err != nil {
+				l.logger.Warn("Failed to refresh distributed lock, abandoning", zap.String("key", l.key), zap.Error(err))
+				l.closeDone()
+				return
 			}
-			c.mutex.Unlock()
-		case <-c.stopCh:
+			if result == 0 {
+				l.logger.Warn("Lost distributed lock to another holder", zap.String("key", l.key))
+				l.closeDone()
+				return
+			}
+		case <-l.stopCh:
 			return
 		}
 	}
 }
 
-var ErrCacheMiss = fmt.Errorf("cache miss")
+func (l *redisLock) closeDone() {
+	l.doneOnce.Do(func() { close(l.done) })
+}
+
+func (l *redisLock) Done() <-chan struct{} {
+	return l.done
+}
+
+// Unlock stops the refresher and releases the lock via lockReleaseScript's
+// CAS compare. The refresher is always stopped, even if the release fails
+// or the lock had already been lost to another holder - this process has
+// no further business touching key either way.
+func (l *redisLock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+
+	result, err := lockReleaseScript.Run(ctx, l.client, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return ErrLockLost
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// Invalidator is implemented by L2 backends (RedisCache) that can
+// broadcast a key invalidation to every other process sharing the same
+// L2, so their TieredCache L1s stay coherent. An L2 that doesn't
+// implement it (MemoryCache, EtcdCache) just means TieredCache's
+// L1 is only coherent within this process - still correct, since every
+// write invalidates the local L1 entry regardless.
+type Invalidator interface {
+	PublishInvalidation(ctx context.Context, key string) error
+	SubscribeInvalidations(ctx context.Context) (keys <-chan string, closeSub func() error)
+}
+
+// TieredCache composes a hot in-process MemoryCache (L1) in front of any
+// other Cache (L2, typically Redis) and implements Cache itself. Reads
+// check L1 first and promote an L2 hit into it; writes go through to L2
+// and drop the local L1 entry rather than trying to keep it in sync
+// in-place. If L2 implements Invalidator, TieredCache also subscribes to
+// its invalidation channel so a write from another process's TieredCache
+// drops this process's stale L1 copy too.
+type TieredCache struct {
+	l1     *MemoryCache
+	l2     Cache
+	logger *zap.Logger
+
+	// sf coalesces concurrent L2 reads for the same key into one L2.Get
+	// call, so a cache stampede on a newly-expired hot key doesn't turn
+	// into N simultaneous L2 round trips.
+	sf singleflight.Group
+
+	statsMu       sync.Mutex
+	l1Hits        int64
+	l2Hits        int64
+	misses        int64
+	invalidations int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTieredCache wraps l1 in front of l2. If l2 implements Invalidator, a
+// background goroutine subscribes to its invalidation channel for the
+// lifetime of the TieredCache; call Close to stop it.
+func NewTieredCache(l1 *MemoryCache, l2 Cache, logger *zap.Logger) *TieredCache {
+	tc := &TieredCache{
+		l1:     l1,
+		l2:     l2,
+		logger: logger,
+		stopCh: make(chan struct{}),
+	}
+
+	if inv, ok := l2.(Invalidator); ok {
+		go tc.watchInvalidations(inv)
+	}
+
+	return tc
+}
+
+func (tc *TieredCache) watchInvalidations(inv Invalidator) {
+	keys, closeSub := inv.SubscribeInvalidations(context.Background())
+	defer closeSub()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			tc.l1.Delete(context.Background(), key)
+			tc.recordInvalidation()
+		case <-tc.stopCh:
+			return
+		}
+	}
+}
+
+func (tc *TieredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	var l1Value interface{}
+	if err := tc.l1.Get(ctx, key, &l1Value); err == nil {
+		tc.recordHit(&tc.l1Hits)
+		return decodeInto(l1Value, dest)
+	}
+
+	result, err, _ := tc.sf.Do(key, func() (interface{}, error) {
+		var l2Value interface{}
+		if err := tc.l2.Get(ctx, key, &l2Value); err != nil {
+			return nil, err
+		}
+		return l2Value, nil
+	})
+	if err != nil {
+		tc.recordHit(&tc.misses)
+		return err
+	}
+
+	tc.recordHit(&tc.l2Hits)
+
+	if err := tc.l1.Set(ctx, key, result); err != nil {
+		tc.logger.Warn("Failed to promote L2 hit into L1", zap.String("key", key), zap.Error(err))
+	}
+
+	return decodeInto(result, dest)
+}
+
+func (tc *TieredCache) Set(ctx context.Context, key string, value interface{}) error {
+	if err := tc.l2.Set(ctx, key, value); err != nil {
+		return err
+	}
+	return tc.invalidateLocal(ctx, key)
+}
+
+func (tc *TieredCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := tc.l2.SetWithTTL(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return tc.invalidateLocal(ctx, key)
+}
+
+func (tc *TieredCache) Delete(ctx context.Context, key string) error {
+	if err := tc.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	return tc.invalidateLocal(ctx, key)
+}
+
+// invalidateLocal drops key from this process's L1 and, if L2 supports it,
+// publishes the invalidation so other processes sharing L2 drop it too.
+func (tc *TieredCache) invalidateLocal(ctx context.Context, key string) error {
+	tc.l1.Delete(ctx, key)
+	tc.recordInvalidation()
+
+	if inv, ok := tc.l2.(Invalidator); ok {
+		if err := inv.PublishInvalidation(ctx, key); err != nil {
+			tc.logger.Warn("Failed to publish cache invalidation", zap.String("key", key), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// Exists, GetTTL, and the counters below go straight to L2 - an L1 copy of
+// a TTL or counter would go stale the instant another process touched it,
+// so tiering them would trade correctness for a cache hit that isn't safe
+// to trust.
+func (tc *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	return tc.l2.Exists(ctx, key)
+}
+
+func (tc *TieredCache) GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	return tc.l2.GetTTL(ctx, key)
+}
+
+func (tc *TieredCache) Increment(ctx context.Context, key string) (int64, error) {
+	n, err := tc.l2.Increment(ctx, key)
+	if err == nil {
+		tc.invalidateLocal(ctx, key)
+	}
+	return n, err
+}
+
+func (tc *TieredCache) IncrementWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	n, err := tc.l2.IncrementWithTTL(ctx, key, ttl)
+	if err == nil {
+		tc.invalidateLocal(ctx, key)
+	}
+	return n, err
+}
+
+// Lock goes straight to L2, for the same reason Exists/GetTTL/the counters
+// do - a lock is inherently cross-process coordination state that an L1
+// copy could never safely reflect.
+func (tc *TieredCache) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	return tc.l2.Lock(ctx, key, ttl)
+}
+
+func (tc *TieredCache) GetStats(ctx context.Context) (*CacheStats, error) {
+	stats, err := tc.l2.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.statsMu.Lock()
+	stats.L1Hits = tc.l1Hits
+	stats.L2Hits = tc.l2Hits
+	stats.Misses = tc.misses
+	stats.Invalidations = tc.invalidations
+	tc.statsMu.Unlock()
+
+	return stats, nil
+}
+
+func (tc *TieredCache) Close() error {
+	tc.stopOnce.Do(func() { close(tc.stopCh) })
+
+	if err := tc.l1.Close(); err != nil {
+		return err
+	}
+	return tc.l2.Close()
+}
+
+func (tc *TieredCache) recordHit(counter *int64) {
+	tc.statsMu.Lock()
+	*counter++
+	tc.statsMu.Unlock()
+}
+
+func (tc *TieredCache) recordInvalidation() {
+	tc.statsMu.Lock()
+	tc.invalidations++
+	tc.statsMu.Unlock()
+}
+
+// decodeInto round-trips value through JSON into dest, since both L1 (via
+// MemoryCache's *interface{} CacheItem.Value) and L2 (via a generic
+// interface{} unmarshal in Get above) hand back a generic decoded value
+// rather than the caller's concrete type.
+func decodeInto(value interface{}, dest interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
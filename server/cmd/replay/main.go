@@ -0,0 +1,61 @@
+// Command replay inspects and re-runs request captures written by
+// middleware.Reproducer. It talks to a running server's admin API rather
+// than reading the NDJSON capture files directly, since only the server
+// process has the in-process router needed to actually replay a request.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	var (
+		serverURL = flag.String("server", "http://localhost:8080", "base URL of the running motorcycle-cv server")
+		captureID = flag.String("capture-id", "", "ID of the capture to replay (see the server's capture log)")
+		token     = flag.String("token", "", "admin bearer token")
+	)
+	flag.Parse()
+
+	if *captureID == "" {
+		fmt.Fprintln(os.Stderr, "usage: replay -capture-id <id> [-server http://host:port] [-token <admin-jwt>]")
+		os.Exit(2)
+	}
+
+	body, err := json.Marshal(map[string]string{"capture_id": *captureID})
+	if err != nil {
+		log.Fatalf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *serverURL+"/api/v1/admin/replay", bytes.NewReader(body))
+	if err != nil {
+		log.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("replay request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Fatalf("failed to decode replay response: %v", err)
+	}
+
+	pretty, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to format replay response: %v", err)
+	}
+
+	fmt.Println(string(pretty))
+}
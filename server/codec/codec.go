@@ -0,0 +1,127 @@
+// Package codec implements the small set of wire formats WebSocket frame
+// transport can negotiate via Sec-WebSocket-Protocol: plain JSON, MessagePack,
+// and a minimal hand-rolled protobuf encoding (see protobuf_wire.go - there's
+// no protoc in this build, so it's a direct varint/length-delimited writer
+// rather than generated code).
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// FrameHeader precedes the raw frame bytes on a binary WebSocket message.
+// Sequence lets a receiver detect frames dropped or reordered in transit.
+type FrameHeader struct {
+	Sequence  uint64 `json:"sequence" msgpack:"sequence"`
+	Timestamp int64  `json:"timestamp" msgpack:"timestamp"`
+	ClientID  string `json:"client_id" msgpack:"client_id"`
+	Format    string `json:"format" msgpack:"format"` // "jpeg" or "h264"
+}
+
+// HeaderCodec encodes/decodes a FrameHeader for the binary WebSocket path.
+type HeaderCodec interface {
+	Name() string
+	ContentType() string
+	EncodeHeader(h *FrameHeader) ([]byte, error)
+	DecodeHeader(data []byte) (*FrameHeader, error)
+}
+
+// SupportedSubprotocols is passed to websocket.Upgrader.Subprotocols, most
+// preferred first, so gorilla/websocket picks the best one the client also
+// offers during the handshake.
+var SupportedSubprotocols = []string{"protobuf", "msgpack", "json"}
+
+// ForSubprotocol returns the HeaderCodec matching a negotiated
+// Sec-WebSocket-Protocol value, defaulting to JSON for an empty or
+// unrecognized one so older clients that don't negotiate keep working.
+func ForSubprotocol(protocol string) HeaderCodec {
+	switch protocol {
+	case "msgpack":
+		return msgpackHeaderCodec{}
+	case "protobuf":
+		return protobufHeaderCodec{}
+	default:
+		return jsonHeaderCodec{}
+	}
+}
+
+type jsonHeaderCodec struct{}
+
+func (jsonHeaderCodec) Name() string        { return "json" }
+func (jsonHeaderCodec) ContentType() string { return "application/json" }
+
+func (jsonHeaderCodec) EncodeHeader(h *FrameHeader) ([]byte, error) {
+	return json.Marshal(h)
+}
+
+func (jsonHeaderCodec) DecodeHeader(data []byte) (*FrameHeader, error) {
+	var h FrameHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("decode json frame header: %w", err)
+	}
+	return &h, nil
+}
+
+type msgpackHeaderCodec struct{}
+
+func (msgpackHeaderCodec) Name() string        { return "msgpack" }
+func (msgpackHeaderCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackHeaderCodec) EncodeHeader(h *FrameHeader) ([]byte, error) {
+	return msgpack.Marshal(h)
+}
+
+func (msgpackHeaderCodec) DecodeHeader(data []byte) (*FrameHeader, error) {
+	var h FrameHeader
+	if err := msgpack.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("decode msgpack frame header: %w", err)
+	}
+	return &h, nil
+}
+
+type protobufHeaderCodec struct{}
+
+func (protobufHeaderCodec) Name() string        { return "protobuf" }
+func (protobufHeaderCodec) ContentType() string { return "application/x-protobuf" }
+
+// Field numbers for the hand-rolled FrameHeader wire encoding below.
+const (
+	fieldSequence  = 1
+	fieldTimestamp = 2
+	fieldClientID  = 3
+	fieldFormat    = 4
+)
+
+func (protobufHeaderCodec) EncodeHeader(h *FrameHeader) ([]byte, error) {
+	var buf []byte
+	buf = AppendVarintField(buf, fieldSequence, h.Sequence)
+	buf = AppendVarintField(buf, fieldTimestamp, uint64(h.Timestamp))
+	buf = AppendBytesField(buf, fieldClientID, []byte(h.ClientID))
+	buf = AppendBytesField(buf, fieldFormat, []byte(h.Format))
+	return buf, nil
+}
+
+func (protobufHeaderCodec) DecodeHeader(data []byte) (*FrameHeader, error) {
+	fields, err := ReadFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode protobuf frame header: %w", err)
+	}
+
+	h := &FrameHeader{}
+	if v, ok := fields[fieldSequence].(uint64); ok {
+		h.Sequence = v
+	}
+	if v, ok := fields[fieldTimestamp].(uint64); ok {
+		h.Timestamp = int64(v)
+	}
+	if v, ok := fields[fieldClientID].([]byte); ok {
+		h.ClientID = string(v)
+	}
+	if v, ok := fields[fieldFormat].([]byte); ok {
+		h.Format = string(v)
+	}
+	return h, nil
+}
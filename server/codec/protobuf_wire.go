@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file implements just enough of the protobuf wire format - varint and
+// length-delimited (bytes/string) fields - to encode the flat messages used
+// by the WebSocket binary frame path and the ML client. There's no protoc
+// in this build environment, so it's a direct writer/reader rather than
+// generated code; it intentionally doesn't support nested messages, repeated
+// fields, or any of the other wire types real .proto schemas would need.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(buf []byte, fieldNum int, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+// AppendVarintField appends a tagged varint field, as protoc-gen-go would
+// emit for an int64/uint64/bool scalar field.
+func AppendVarintField(buf []byte, fieldNum int, value uint64) []byte {
+	buf = appendTag(buf, fieldNum, wireVarint)
+	return binary.AppendUvarint(buf, value)
+}
+
+// AppendBytesField appends a tagged length-delimited field, as
+// protoc-gen-go would emit for a string/bytes scalar field.
+func AppendBytesField(buf []byte, fieldNum int, value []byte) []byte {
+	buf = appendTag(buf, fieldNum, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// ReadFields parses a flat sequence of tagged varint/bytes fields into a
+// map keyed by field number, with a uint64 value for varint fields and a
+// []byte value for length-delimited ones. Later occurrences of the same
+// field number overwrite earlier ones, matching proto3 "last one wins"
+// semantics for non-repeated fields.
+func ReadFields(data []byte) (map[int]interface{}, error) {
+	fields := make(map[int]interface{})
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed field tag")
+		}
+		data = data[n:]
+
+		fieldNum := int(tag >> 3)
+		wireType := int(tag & 0x7)
+
+		switch wireType {
+		case wireVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("malformed varint field %d", fieldNum)
+			}
+			data = data[n:]
+			fields[fieldNum] = value
+
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("malformed length prefix for field %d", fieldNum)
+			}
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return nil, fmt.Errorf("truncated bytes field %d", fieldNum)
+			}
+			fields[fieldNum] = data[:length]
+			data = data[length:]
+
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d for field %d", wireType, fieldNum)
+		}
+	}
+
+	return fields, nil
+}
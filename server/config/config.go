@@ -26,6 +26,13 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `json:"write_timeout"`
 	IdleTimeout  time.Duration `json:"idle_timeout"`
 	Environment  string        `json:"environment"`
+	UploadDir    string        `json:"upload_dir"`
+	// MaxUploadSize bounds Upload-Length for the tus-style resumable upload
+	// protocol (handlers.UploadHandler), separate from Security.MaxRequestSize
+	// which bounds a single request body - resumable uploads are deliberately
+	// for files larger than that limit, but still need a ceiling so a client
+	// can't accumulate an unbounded file on disk via many small PATCH calls.
+	MaxUploadSize int64 `json:"max_upload_size"`
 }
 
 type MLConfig struct {
@@ -34,18 +41,28 @@ type MLConfig struct {
 	MaxRetries          int           `json:"max_retries"`
 	RetryDelay          time.Duration `json:"retry_delay"`
 	HealthCheckInterval time.Duration `json:"health_check_interval"`
+	RequestEncoding     string        `json:"request_encoding"`
+	Backend             string        `json:"backend"` // "http" (default), "grpc", or "onnx"
+	GRPCAddress         string        `json:"grpc_address"`
+	ONNXModelPath       string        `json:"onnx_model_path"`
 }
 
 type SecurityConfig struct {
-	JWTSecretKey   string        `json:"jwt_secret_key"`
-	AllowedOrigins []string      `json:"allowed_origins"`
-	RateLimitRPS   int           `json:"rate_limit_rps"`
-	RateLimitBurst int           `json:"rate_limit_burst"`
-	MaxRequestSize int64         `json:"max_request_size"`
-	RequestTimeout time.Duration `json:"request_timeout"`
-	EnableHTTPS    bool          `json:"enable_https"`
-	CertFile       string        `json:"cert_file"`
-	KeyFile        string        `json:"key_file"`
+	// JWTAlgorithm selects the signing algorithm AuthMiddleware's
+	// KeyProvider uses: "rsa" or "ed25519". Ignored (and an ephemeral
+	// Ed25519 key generated instead) if JWTPrivateKeyPEM is empty.
+	JWTAlgorithm     string            `json:"jwt_algorithm"`
+	JWTKeyID         string            `json:"jwt_key_id"`
+	JWTPrivateKeyPEM string            `json:"jwt_private_key_pem"`
+	AllowedOrigins   []string          `json:"allowed_origins"`
+	RateLimitRPS     int               `json:"rate_limit_rps"`
+	RateLimitBurst   int               `json:"rate_limit_burst"`
+	MaxRequestSize   int64             `json:"max_request_size"`
+	RequestTimeout   time.Duration     `json:"request_timeout"`
+	EnableHTTPS      bool              `json:"enable_https"`
+	CertFile         string            `json:"cert_file"`
+	KeyFile          string            `json:"key_file"`
+	SigV4Keys        map[string]string `json:"sigv4_keys"`
 }
 
 type DatabaseConfig struct {
@@ -65,6 +82,15 @@ type RedisConfig struct {
 	Password string `json:"password"`
 	DB       int    `json:"db"`
 	PoolSize int    `json:"pool_size"`
+
+	// TLSEnabled, SentinelMasterName/SentinelAddrs, and ClusterAddrs select
+	// which topology cache.NewRedisCacheWithOptions connects to. At most
+	// one of SentinelAddrs/ClusterAddrs should be set; a plain single-node
+	// connection is used when neither is.
+	TLSEnabled         bool     `json:"tls_enabled"`
+	SentinelMasterName string   `json:"sentinel_master_name"`
+	SentinelAddrs      []string `json:"sentinel_addrs"`
+	ClusterAddrs       []string `json:"cluster_addrs"`
 }
 
 type LoggingConfig struct {
@@ -79,12 +105,14 @@ type LoggingConfig struct {
 func LoadConfig() *Config {
 	config := &Config{
 		Server: ServerConfig{
-			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:         getEnvAsInt("SERVER_PORT", 8080),
-			ReadTimeout:  getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
-			WriteTimeout: getEnvAsDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
-			IdleTimeout:  getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
-			Environment:  getEnv("ENVIRONMENT", "development"),
+			Host:          getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:          getEnvAsInt("SERVER_PORT", 8080),
+			ReadTimeout:   getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:  getEnvAsDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			IdleTimeout:   getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			Environment:   getEnv("ENVIRONMENT", "development"),
+			UploadDir:     getEnv("SERVER_UPLOAD_DIR", "/tmp/motorcycle-cv-uploads"),
+			MaxUploadSize: getEnvAsInt64("SERVER_MAX_UPLOAD_SIZE", 2*1024*1024*1024), // 2GB
 		},
 		ML: MLConfig{
 			BaseURL:             getEnv("ML_BASE_URL", "http://localhost:5000"),
@@ -92,17 +120,24 @@ func LoadConfig() *Config {
 			MaxRetries:          getEnvAsInt("ML_MAX_RETRIES", 3),
 			RetryDelay:          getEnvAsDuration("ML_RETRY_DELAY", 1*time.Second),
 			HealthCheckInterval: getEnvAsDuration("ML_HEALTH_CHECK_INTERVAL", 30*time.Second),
+			RequestEncoding:     getEnv("ML_REQUEST_ENCODING", "json"),
+			Backend:             getEnv("ML_BACKEND", "http"),
+			GRPCAddress:         getEnv("ML_GRPC_ADDRESS", "localhost:50051"),
+			ONNXModelPath:       getEnv("ML_ONNX_MODEL_PATH", ""),
 		},
 		Security: SecurityConfig{
-			JWTSecretKey:   getEnv("JWT_SECRET_KEY", ""),
-			AllowedOrigins: getEnvAsStringSlice("ALLOWED_ORIGINS", []string{"*"}),
-			RateLimitRPS:   getEnvAsInt("RATE_LIMIT_RPS", 100),
-			RateLimitBurst: getEnvAsInt("RATE_LIMIT_BURST", 200),
-			MaxRequestSize: getEnvAsInt64("MAX_REQUEST_SIZE", 10*1024*1024), // 10MB
-			RequestTimeout: getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
-			EnableHTTPS:    getEnvAsBool("ENABLE_HTTPS", false),
-			CertFile:       getEnv("CERT_FILE", ""),
-			KeyFile:        getEnv("KEY_FILE", ""),
+			JWTAlgorithm:     getEnv("JWT_ALGORITHM", "ed25519"),
+			JWTKeyID:         getEnv("JWT_KEY_ID", "default"),
+			JWTPrivateKeyPEM: getEnv("JWT_PRIVATE_KEY_PEM", ""),
+			AllowedOrigins:   getEnvAsStringSlice("ALLOWED_ORIGINS", []string{"*"}),
+			RateLimitRPS:     getEnvAsInt("RATE_LIMIT_RPS", 100),
+			RateLimitBurst:   getEnvAsInt("RATE_LIMIT_BURST", 200),
+			MaxRequestSize:   getEnvAsInt64("MAX_REQUEST_SIZE", 10*1024*1024), // 10MB
+			RequestTimeout:   getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
+			EnableHTTPS:      getEnvAsBool("ENABLE_HTTPS", false),
+			CertFile:         getEnv("CERT_FILE", ""),
+			KeyFile:          getEnv("KEY_FILE", ""),
+			SigV4Keys:        getEnvAsKeyValueMap("SIGV4_ACCESS_KEYS", nil),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -115,11 +150,15 @@ func LoadConfig() *Config {
 			MinConns: getEnvAsInt("DB_MIN_CONNS", 5),
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnvAsInt("REDIS_PORT", 6379),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
-			PoolSize: getEnvAsInt("REDIS_POOL_SIZE", 10),
+			Host:               getEnv("REDIS_HOST", "localhost"),
+			Port:               getEnvAsInt("REDIS_PORT", 6379),
+			Password:           getEnv("REDIS_PASSWORD", ""),
+			DB:                 getEnvAsInt("REDIS_DB", 0),
+			PoolSize:           getEnvAsInt("REDIS_POOL_SIZE", 10),
+			TLSEnabled:         getEnvAsBool("REDIS_TLS_ENABLED", false),
+			SentinelMasterName: getEnv("REDIS_SENTINEL_MASTER_NAME", ""),
+			SentinelAddrs:      getEnvAsStringSlice("REDIS_SENTINEL_ADDRS", nil),
+			ClusterAddrs:       getEnvAsStringSlice("REDIS_CLUSTER_ADDRS", nil),
 		},
 		Logging: LoggingConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
@@ -145,14 +184,18 @@ func (c *Config) ValidateConfig(logger *zap.Logger) error {
 		errors = append(errors, "ML base URL is required")
 	}
 
-	if c.Security.JWTSecretKey == "" {
-		logger.Warn("JWT secret key not set, using random key")
+	if c.Security.JWTPrivateKeyPEM == "" {
+		logger.Warn("JWT signing key not configured, an ephemeral key will be generated at startup")
 	}
 
 	if c.Security.MaxRequestSize <= 0 {
 		errors = append(errors, "max request size must be positive")
 	}
 
+	if c.Server.MaxUploadSize <= 0 {
+		errors = append(errors, "max upload size must be positive")
+	}
+
 	if c.Database.Host == "" {
 		errors = append(errors, "database host is required")
 	}
@@ -176,7 +219,6 @@ func (c *Config) ValidateConfig(logger *zap.Logger) error {
 	return nil
 }
 
-
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -226,3 +268,23 @@ func getEnvAsStringSlice(key string, defaultValue []string) []string {
 	}
 	return defaultValue
 }
+
+// getEnvAsKeyValueMap parses a comma-separated list of "key:value" pairs,
+// e.g. SIGV4_ACCESS_KEYS="AKIDEXAMPLE:secret1,AKIDOTHER:secret2". Entries
+// that don't contain a colon are skipped.
+func getEnvAsKeyValueMap(key string, defaultValue map[string]string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result
+}
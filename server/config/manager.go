@@ -0,0 +1,264 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrFingerprintMismatch is returned by Manager.DoLockedAction when the
+// fingerprint the caller last observed no longer matches the current
+// config, meaning another update (an admin API call, a file watcher tick)
+// already moved it on. Callers should re-read Manager.Fingerprint and
+// Manager.Current and retry.
+var ErrFingerprintMismatch = errors.New("config: fingerprint mismatch, config changed since last read")
+
+// Provider loads the effective configuration from some source - env vars,
+// a JSON file, or (not implemented here) a service like Consul/etcd. Any
+// type satisfying this interface can be passed to NewManager.
+type Provider interface {
+	Load() (*Config, error)
+}
+
+// EnvProvider loads configuration from environment variables via
+// LoadConfig. It never changes unless the process's env does, so it's
+// mainly useful as Manager's initial source when hot-reload will instead
+// come from DoLockedAction calls (an admin API) rather than polling.
+type EnvProvider struct{}
+
+func (EnvProvider) Load() (*Config, error) {
+	return LoadConfig(), nil
+}
+
+// FileProvider loads configuration from a JSON file at Path, letting an
+// operator hot-reload config by editing and re-deploying that file instead
+// of restarting the process. A Consul/etcd-backed Provider would look the
+// same shape - Load pulls the latest value - but isn't implemented here
+// since this tree has no way to reach either service.
+type FileProvider struct {
+	Path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{Path: path}
+}
+
+func (p *FileProvider) Load() (*Config, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Fingerprint computes a stable SHA-256 digest over the config's canonical
+// JSON encoding (json.Marshal already serializes struct fields in
+// declaration order and map keys sorted, so the same Config value always
+// produces the same fingerprint). Manager.DoLockedAction callers use this
+// to detect whether the config changed under them since they last read it.
+func Fingerprint(cfg *Config) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Watcher is how a subsystem learns about config changes: Manager sends
+// the new Config on this channel after every successful update. Sends are
+// non-blocking (buffer of 1, newest value wins), so a slow or momentarily
+// busy subscriber just misses an intermediate value rather than stalling
+// every other watcher or the updating caller.
+type Watcher <-chan *Config
+
+// Manager holds the current effective Config and the fingerprint of its
+// canonical JSON encoding, borrowing the fingerprint-guarded update
+// pattern from openbmclapi's ConfigHandler so two racing writers (e.g. an
+// admin API call and a file-watcher reload) can't silently clobber each
+// other - whichever one's DoLockedAction call observes a stale
+// fingerprint gets ErrFingerprintMismatch back instead of overwriting the
+// other's change.
+type Manager struct {
+	mutex       sync.RWMutex
+	current     *Config
+	fingerprint string
+
+	provider     Provider
+	pollInterval time.Duration
+	logger       *zap.Logger
+
+	watchers []chan *Config
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewManager loads the initial config from provider and, if pollInterval
+// is positive, starts a background loop that reloads from provider on
+// that interval and applies the result if it differs from the current
+// config. A pollInterval of 0 disables polling - appropriate for
+// EnvProvider, where nothing external can change the source out from
+// under the running process anyway.
+func NewManager(provider Provider, pollInterval time.Duration, logger *zap.Logger) (*Manager, error) {
+	cfg, err := provider.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := Fingerprint(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		current:      cfg,
+		fingerprint:  fp,
+		provider:     provider,
+		pollInterval: pollInterval,
+		logger:       logger,
+		stopCh:       make(chan struct{}),
+	}
+
+	if pollInterval > 0 {
+		go m.pollLoop()
+	}
+
+	return m, nil
+}
+
+// Current returns the currently effective config. The returned value must
+// be treated as read-only; mutate config through DoLockedAction instead.
+func (m *Manager) Current() *Config {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.current
+}
+
+// Fingerprint returns the fingerprint of the currently effective config,
+// for callers that need to pass it to a later DoLockedAction call.
+func (m *Manager) Fingerprint() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.fingerprint
+}
+
+// Subscribe registers a new Watcher that receives the updated config after
+// every successful change (both DoLockedAction calls and poll-driven
+// reloads). Subscribers are expected to live for the lifetime of the
+// Manager; there's no Unsubscribe since every current caller (ml.Client,
+// RateLimiter, WebSocketHandler) is wired up once at server startup and
+// torn down along with the process.
+func (m *Manager) Subscribe() Watcher {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ch := make(chan *Config, 1)
+	m.watchers = append(m.watchers, ch)
+	return ch
+}
+
+// DoLockedAction applies fn to a copy of the current config, but only if
+// fingerprint still matches the current one - otherwise it returns
+// ErrFingerprintMismatch without calling fn. On success the new config
+// replaces the current one and every subscribed Watcher is notified.
+func (m *Manager) DoLockedAction(fingerprint string, fn func(*Config) error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if fingerprint != m.fingerprint {
+		return ErrFingerprintMismatch
+	}
+
+	updated := *m.current
+	if err := fn(&updated); err != nil {
+		return err
+	}
+
+	newFingerprint, err := Fingerprint(&updated)
+	if err != nil {
+		return err
+	}
+
+	m.current = &updated
+	m.fingerprint = newFingerprint
+	m.notifyLocked()
+	return nil
+}
+
+func (m *Manager) notifyLocked() {
+	for _, ch := range m.watchers {
+		select {
+		case ch <- m.current:
+		default:
+			// Drop the stale pending value and replace it with the latest,
+			// rather than letting a slow subscriber fall arbitrarily far
+			// behind.
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- m.current:
+			default:
+			}
+		}
+	}
+}
+
+func (m *Manager) pollLoop() {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.reload()
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+func (m *Manager) reload() {
+	cfg, err := m.provider.Load()
+	if err != nil {
+		m.logger.Warn("Failed to reload config", zap.Error(err))
+		return
+	}
+
+	newFingerprint, err := Fingerprint(cfg)
+	if err != nil {
+		m.logger.Warn("Failed to fingerprint reloaded config", zap.Error(err))
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if newFingerprint == m.fingerprint {
+		return
+	}
+
+	m.current = cfg
+	m.fingerprint = newFingerprint
+	m.notifyLocked()
+	m.logger.Info("Config reloaded", zap.String("fingerprint", newFingerprint[:12]))
+}
+
+// Close stops the poll loop, if running. Safe to call more than once.
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+}
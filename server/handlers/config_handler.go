@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/config"
+	"go.uber.org/zap"
+)
+
+// ConfigHandler exposes config.Manager over HTTP so an admin can
+// hot-reload the subset of settings watchConfig propagates (ML client
+// tuning, rate limits) without a restart, going through the same
+// fingerprint-guarded config.Manager.DoLockedAction path a concurrent
+// FileProvider reload would use - so an admin update and a file-watcher
+// tick can't silently clobber each other.
+type ConfigHandler struct {
+	manager *config.Manager
+	logger  *zap.Logger
+}
+
+func NewConfigHandler(manager *config.Manager, logger *zap.Logger) *ConfigHandler {
+	return &ConfigHandler{manager: manager, logger: logger}
+}
+
+// configUpdateRequest is deliberately a subset of config.Config - only the
+// fields watchConfig already knows how to hot-apply - rather than the whole
+// struct, so a request can't blow away fields it never intended to touch
+// (e.g. DB credentials) by omission.
+type configUpdateRequest struct {
+	Fingerprint    string         `json:"fingerprint" binding:"required"`
+	RateLimitRPS   *int           `json:"rate_limit_rps"`
+	RateLimitBurst *int           `json:"rate_limit_burst"`
+	MLTimeout      *time.Duration `json:"ml_timeout"`
+	MLMaxRetries   *int           `json:"ml_max_retries"`
+}
+
+// GetConfig handles GET /admin/config, returning the currently effective
+// config and its fingerprint so a caller can build an UpdateConfig request.
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"config":      h.manager.Current(),
+		"fingerprint": h.manager.Fingerprint(),
+	})
+}
+
+// UpdateConfig handles PATCH /admin/config: applies any fields present in
+// the request body to a copy of the current config via DoLockedAction, but
+// only if Fingerprint still matches the current one. A stale fingerprint
+// (another admin call or a file-watcher reload already moved it) gets a
+// 409 back instead of silently clobbering that other change.
+func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
+	var req configUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.manager.DoLockedAction(req.Fingerprint, func(cfg *config.Config) error {
+		if req.RateLimitRPS != nil {
+			cfg.Security.RateLimitRPS = *req.RateLimitRPS
+		}
+		if req.RateLimitBurst != nil {
+			cfg.Security.RateLimitBurst = *req.RateLimitBurst
+		}
+		if req.MLTimeout != nil {
+			cfg.ML.Timeout = *req.MLTimeout
+		}
+		if req.MLMaxRetries != nil {
+			cfg.ML.MaxRetries = *req.MLMaxRetries
+		}
+		return nil
+	})
+
+	if errors.Is(err, config.ErrFingerprintMismatch) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       err.Error(),
+			"fingerprint": h.manager.Fingerprint(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Config updated via admin API")
+	c.JSON(http.StatusOK, gin.H{
+		"config":      h.manager.Current(),
+		"fingerprint": h.manager.Fingerprint(),
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/san-kum/motorcycle-cv/server/processor"
+	"go.uber.org/zap"
+)
+
+// DebugHandler exposes the FrameProcessor's PipelineObserver hook for live
+// inspection: GET /debug/live streams every FrameEvent as it happens, and
+// GET /debug/stats returns a rolling snapshot of per-client throughput,
+// latency, drop, and retry stats. Neither endpoint is on the hot path -
+// FrameProcessor.SetObserver(h.Observer()) is the only place they're wired
+// into it, at server startup.
+type DebugHandler struct {
+	logger   *zap.Logger
+	upgrader websocket.Upgrader
+	stats    *processor.ClientStatsObserver
+	live     *liveObserver
+}
+
+func NewDebugHandler(logger *zap.Logger) *DebugHandler {
+	return &DebugHandler{
+		logger: logger,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+		stats: processor.NewClientStatsObserver(),
+		live:  newLiveObserver(),
+	}
+}
+
+// Observer returns the PipelineObserver to register via
+// FrameProcessor.SetObserver. It fans every FrameEvent out to both the
+// rolling stats aggregator and any connected /debug/live subscribers.
+func (h *DebugHandler) Observer() processor.PipelineObserver {
+	return fanOutObserver{h.stats, h.live}
+}
+
+// GetStats handles GET /debug/stats, returning the current per-client
+// snapshot (see processor.ClientStatsObserver.Snapshot).
+func (h *DebugHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"clients": h.stats.Snapshot()})
+}
+
+// HandleLive handles GET /debug/live, upgrading to a WebSocket and
+// streaming every FrameEvent observed from here on as JSON until the
+// connection closes.
+func (h *DebugHandler) HandleLive(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Error("failed to upgrade debug live connection", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	events := h.live.subscribe()
+	defer h.live.unsubscribe(events)
+
+	for event := range events {
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
+
+// fanOutObserver calls every PipelineObserver in order. It's what glues
+// ClientStatsObserver and liveObserver - two observers with nothing to do
+// with each other - into the single PipelineObserver FrameProcessor.SetObserver
+// expects, the same way main.go's watchConfig fans one config.Manager update
+// out to several unrelated subsystems.
+type fanOutObserver []processor.PipelineObserver
+
+func (f fanOutObserver) ObserveFrame(event processor.FrameEvent) {
+	for _, obs := range f {
+		obs.ObserveFrame(event)
+	}
+}
+
+// liveObserver is a PipelineObserver that rebroadcasts every FrameEvent to
+// each subscribed /debug/live connection. Each subscriber gets a buffer-1
+// channel with drop-oldest-replace-with-latest semantics, mirroring
+// config.Manager's Watcher notify - a slow viewer misses intermediate
+// events instead of blocking ObserveFrame, which runs inline on the hot
+// path via defer.
+type liveObserver struct {
+	mutex       sync.Mutex
+	subscribers map[chan processor.FrameEvent]struct{}
+}
+
+func newLiveObserver() *liveObserver {
+	return &liveObserver{subscribers: make(map[chan processor.FrameEvent]struct{})}
+}
+
+func (o *liveObserver) subscribe() chan processor.FrameEvent {
+	ch := make(chan processor.FrameEvent, 1)
+	o.mutex.Lock()
+	o.subscribers[ch] = struct{}{}
+	o.mutex.Unlock()
+	return ch
+}
+
+func (o *liveObserver) unsubscribe(ch chan processor.FrameEvent) {
+	o.mutex.Lock()
+	delete(o.subscribers, ch)
+	o.mutex.Unlock()
+	close(ch)
+}
+
+func (o *liveObserver) ObserveFrame(event processor.FrameEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for ch := range o.subscribers {
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
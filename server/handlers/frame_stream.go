@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/san-kum/motorcycle-cv/server/models"
+)
+
+// maxFrameStreamFrameSize bounds a single length-prefixed frame read by
+// readLengthPrefixedFrame, matching the limit HandleWebSocket sets via
+// conn.SetReadLimit for the same reason: the length prefix is attacker-
+// controlled, so without a cap a single request can force an allocation as
+// large as the uint32 length field allows (~4GB), repeatably.
+const maxFrameStreamFrameSize = 10 * 1024 * 1024
+
+// frameStreamContentTypes lists the Content-Type values accepted by
+// AnalyzeFrameStream, matched against InputValidation's allow-list for
+// this endpoint.
+var frameStreamContentTypes = map[string]bool{
+	"image/jpeg":                   true,
+	"image/png":                    true,
+	"application/x-yuv4mpegstream": true,
+}
+
+// AnalyzeFrameStream handles POST /api/v1/analyze-frame-stream: a
+// length-prefixed sequence of raw frames (no base64, no per-frame JSON
+// envelope) pumped straight into FrameProcessor, with results streamed
+// back as newline-delimited JSON on the same connection. This replaces
+// the ~33% bandwidth overhead and per-frame string allocation that
+// base64 data URLs cost ProcessFrame, and lets a single HTTP/2 request
+// carry an entire clip instead of one request per frame.
+//
+// Each frame on the wire is a 4-byte big-endian length prefix followed by
+// that many bytes of raw frame data, in the format named by Content-Type
+// (image/jpeg, image/png, or application/x-yuv4mpegstream for Y4M
+// planar frames). The body may additionally be wrapped in gzip, bzip2,
+// or zstd per Content-Encoding.
+func (h *StreamHandler) AnalyzeFrameStream(c *gin.Context) {
+	contentType := c.GetHeader("Content-Type")
+	if !frameStreamContentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported frame content type"})
+		return
+	}
+
+	body, err := decodeContentEncoding(c.Request.Body, c.GetHeader("Content-Encoding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported or invalid content encoding"})
+		return
+	}
+	if closer, ok := body.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	reader := bufio.NewReader(body)
+	clientID := c.ClientIP()
+
+	for {
+		frame, err := readLengthPrefixedFrame(reader)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			h.writeNDJSONError(c, err)
+			return
+		}
+
+		request := &models.FrameRequest{
+			ImageData: frame,
+			Timestamp: time.Now().UnixMilli(),
+			ClientID:  clientID,
+			Metadata: map[string]any{
+				"content_type": contentType,
+			},
+		}
+
+		result, err := h.processor.ProcessFrame(c.Request.Context(), request)
+		if err != nil {
+			h.writeNDJSONError(c, err)
+			continue
+		}
+
+		h.writeNDJSONResult(c, result)
+	}
+}
+
+func (h *StreamHandler) writeNDJSONResult(c *gin.Context, result *models.AnalysisResult) {
+	line, err := json.Marshal(gin.H{"analysis": result})
+	if err != nil {
+		h.logger.Warn("Failed to marshal frame-stream result")
+		return
+	}
+	c.Writer.Write(line)
+	c.Writer.Write([]byte("\n"))
+	c.Writer.Flush()
+}
+
+func (h *StreamHandler) writeNDJSONError(c *gin.Context, err error) {
+	line, marshalErr := json.Marshal(gin.H{"error": err.Error()})
+	if marshalErr != nil {
+		return
+	}
+	c.Writer.Write(line)
+	c.Writer.Write([]byte("\n"))
+	c.Writer.Flush()
+}
+
+// readLengthPrefixedFrame reads one 4-byte big-endian length prefix
+// followed by that many bytes of frame data. length is rejected before
+// allocating if it exceeds maxFrameStreamFrameSize, since it comes straight
+// off the wire from the client.
+func readLengthPrefixedFrame(r io.Reader) ([]byte, error) {
+	var lengthBuf [4]byte
+	if _, err := io.ReadFull(r, lengthBuf[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(lengthBuf[:])
+	if length > maxFrameStreamFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds maximum of %d bytes", length, maxFrameStreamFrameSize)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// decodeContentEncoding wraps body in the decompressor named by encoding,
+// or returns it unchanged for an empty/"identity" encoding.
+func decodeContentEncoding(body io.ReadCloser, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		return gzip.NewReader(body)
+	case "bzip2":
+		return bzip2.NewReader(body), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, errors.New("unknown content encoding: " + encoding)
+	}
+}
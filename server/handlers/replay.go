@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/middleware"
+)
+
+// ReplayHandler re-runs a captured request (see middleware.Reproducer)
+// through the live router in-process, so a 5xx response or a crashing
+// frame can be reproduced and diffed against what happened originally
+// without asking the reporting user to resend whatever triggered it.
+type ReplayHandler struct {
+	reproducer *middleware.Reproducer
+	router     http.Handler
+}
+
+func NewReplayHandler(reproducer *middleware.Reproducer, router http.Handler) *ReplayHandler {
+	return &ReplayHandler{reproducer: reproducer, router: router}
+}
+
+type replayRequest struct {
+	CaptureID string `json:"capture_id" binding:"required"`
+}
+
+type replayResponse struct {
+	Capture       *middleware.Capture `json:"capture"`
+	NewStatusCode int                 `json:"new_status_code"`
+	NewBody       string              `json:"new_body"`
+	StatusChanged bool                `json:"status_changed"`
+	BodyChanged   bool                `json:"body_changed"`
+}
+
+// Replay handles POST /api/v1/admin/replay: looks up the capture by ID,
+// reconstructs the original request, and runs it through the router
+// in-process via httptest so the diagnosis doesn't need a second live
+// server or the original client.
+func (h *ReplayHandler) Replay(c *gin.Context) {
+	var req replayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "capture_id required"})
+		return
+	}
+
+	capture, err := h.reproducer.Load(req.CaptureID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if capture.Method == "WORKER" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "capture is a worker crash, not a replayable HTTP request"})
+		return
+	}
+
+	body, err := base64.StdEncoding.DecodeString(capture.BodyBase64)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode captured request body"})
+		return
+	}
+
+	originalResponseBody, err := base64.StdEncoding.DecodeString(capture.ResponseBodyBase64)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode captured response body"})
+		return
+	}
+
+	url := capture.Path
+	if capture.Query != "" {
+		url += "?" + capture.Query
+	}
+
+	replayReq := httptest.NewRequest(capture.Method, url, bytes.NewReader(body))
+	for key, values := range capture.Headers {
+		for _, v := range values {
+			replayReq.Header.Add(key, v)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, replayReq)
+
+	newBody := recorder.Body.Bytes()
+
+	c.JSON(http.StatusOK, replayResponse{
+		Capture:       capture,
+		NewStatusCode: recorder.Code,
+		NewBody:       string(newBody),
+		StatusChanged: recorder.Code != capture.StatusCode,
+		BodyChanged:   !bytes.Equal(newBody, originalResponseBody),
+	})
+}
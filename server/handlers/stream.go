@@ -70,7 +70,7 @@ func (h *StreamHandler) ProcessFrame(c *gin.Context) {
 		ClientID:  c.ClientIP(),
 	}
 
-	result, err := h.processor.ProcessFrame(frameRequest)
+	result, err := h.processor.ProcessFrame(c.Request.Context(), frameRequest)
 	if err != nil {
 		h.logger.Error("Frame processing failed",
 			zap.Error(err),
@@ -116,6 +116,13 @@ func (h *StreamHandler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetHumanStats returns throughput/dedup/latency stats with humanized
+// units (KB/MB/GB, k/M suffixes, percentiles) for quick operator
+// consumption instead of the raw counters GetStats exposes.
+func (h *StreamHandler) GetHumanStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.processor.GetHumanReadableStats())
+}
+
 func (h *StreamHandler) UploadVideo(c *gin.Context) {
 	file, header, err := c.Request.FormFile("video")
 	if err != nil {
@@ -151,6 +158,49 @@ func (h *StreamHandler) UploadVideo(c *gin.Context) {
 	})
 }
 
+// GetVideoManifest serves the DASH MPD for a completed video job so the
+// front-end can hand the URL straight to dash.js instead of polling
+// GetVideoJobStatus for results.
+func (h *StreamHandler) GetVideoManifest(c *gin.Context) {
+	mpd, err := h.processor.GetManifestMPD(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/dash+xml", []byte(mpd))
+}
+
+// GetVideoMasterPlaylist serves the HLS playlist equivalent of
+// GetVideoManifest for hls.js-based players.
+func (h *StreamHandler) GetVideoMasterPlaylist(c *gin.Context) {
+	playlist, err := h.processor.GetMasterPlaylist(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
+}
+
+// GetVideoSegment serves an individual fMP4 segment, the shared init
+// segment, or the WebVTT overlay sidecar referenced by the manifests.
+func (h *StreamHandler) GetVideoSegment(c *gin.Context) {
+	data, err := h.processor.GetMediaFile(c.Param("job_id"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/octet-stream"
+	switch {
+	case strings.HasSuffix(c.Param("name"), ".vtt"):
+		contentType = "text/vtt"
+	case strings.HasSuffix(c.Param("name"), ".m4s"), strings.HasSuffix(c.Param("name"), ".mp4"):
+		contentType = "video/mp4"
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
 func (h *StreamHandler) GetVideoJobStatus(c *gin.Context) {
 	jobID := c.Param("job_id")
 
@@ -199,7 +249,7 @@ func (h *StreamHandler) updateProcessingStats(duration time.Duration) {
 	if h.stats.AvgProcessTime == 0 {
 		h.stats.AvgProcessTime = currentTime
 	} else {
-		alpha := 0.1 
+		alpha := 0.1
 		h.stats.AvgProcessTime = alpha*currentTime + (1-alpha)*h.stats.AvgProcessTime
 	}
 }
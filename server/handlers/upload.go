@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/processor"
+	"go.uber.org/zap"
+)
+
+// UploadHandler implements a tus-style resumable upload protocol for
+// large dash-cam videos, replacing the one-shot io.ReadAll POST that
+// buffered the whole file in memory and capped uploads at 100MB.
+// Chunks are streamed straight to a temp file on disk; the video
+// pipeline runs once the bytes received reach the declared Upload-Length.
+type UploadHandler struct {
+	processor     *processor.FrameProcessor
+	logger        *zap.Logger
+	uploadDir     string
+	maxUploadSize int64
+
+	mutex   sync.RWMutex
+	uploads map[string]*uploadSession
+}
+
+type uploadSession struct {
+	mutex    sync.Mutex
+	id       string
+	filename string
+	clientID string
+	length   int64
+	offset   int64
+	path     string
+}
+
+// defaultMaxUploadSize bounds Upload-Length when NewUploadHandler is given
+// a maxUploadSize <= 0, so a misconfigured deployment still has some cap
+// rather than none.
+const defaultMaxUploadSize = 2 * 1024 * 1024 * 1024 // 2GB
+
+func NewUploadHandler(proc *processor.FrameProcessor, logger *zap.Logger, uploadDir string, maxUploadSize int64) *UploadHandler {
+	if uploadDir == "" {
+		uploadDir = os.TempDir()
+	}
+	os.MkdirAll(uploadDir, 0755)
+
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+
+	return &UploadHandler{
+		processor:     proc,
+		logger:        logger,
+		uploadDir:     uploadDir,
+		maxUploadSize: maxUploadSize,
+		uploads:       make(map[string]*uploadSession),
+	}
+}
+
+// CreateUpload handles POST /api/v1/uploads: creates an upload session of
+// the declared total length (Upload-Length) and returns its URL.
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length header required"})
+		return
+	}
+	if length > h.maxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":           "Upload-Length exceeds maximum allowed upload size",
+			"max_upload_size": h.maxUploadSize,
+		})
+		return
+	}
+
+	filename := c.GetHeader("Upload-Filename")
+	if filename == "" {
+		filename = "upload.mp4"
+	}
+
+	id := generateUploadID(filename, c.ClientIP())
+	session := &uploadSession{
+		id:       id,
+		filename: filename,
+		clientID: c.ClientIP(),
+		length:   length,
+		path:     filepath.Join(h.uploadDir, id),
+	}
+
+	if f, err := os.Create(session.path); err != nil {
+		h.logger.Error("Failed to create upload file", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	} else {
+		f.Close()
+	}
+
+	h.mutex.Lock()
+	h.uploads[id] = session
+	h.mutex.Unlock()
+
+	c.Header("Location", "/api/v1/uploads/"+id)
+	c.Header("Upload-Offset", "0")
+	c.Status(http.StatusCreated)
+}
+
+// HeadUpload handles HEAD /api/v1/uploads/:id, reporting the current
+// Upload-Offset so a client can resume after a dropped connection.
+func (h *UploadHandler) HeadUpload(c *gin.Context) {
+	session, ok := h.session(c.Param("id"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.length, 10))
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload handles PATCH /api/v1/uploads/:id: appends bytes at the
+// offset given by Upload-Offset. Supports Content-Encoding: gzip chunks
+// (decoded transparently before appending) and aws-chunked framing
+// (<hex-size>;chunk-signature=<sig> per-chunk records, read up to the
+// zero-size final chunk; chunk signatures are not verified), matching how
+// large-object S3 gateways push variable-size chunks.
+func (h *UploadHandler) PatchUpload(c *gin.Context) {
+	session, ok := h.session(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset != session.offset {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "offset mismatch",
+			"current_offset": session.offset,
+		})
+		return
+	}
+
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	switch c.GetHeader("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid gzip chunk"})
+			return
+		}
+		defer gz.Close()
+		body = gz
+	case "aws-chunked":
+		body = newAWSChunkedReader(c.Request.Body)
+	}
+
+	f, err := os.OpenFile(session.path, os.O_WRONLY, 0644)
+	if err != nil {
+		h.logger.Error("Failed to open upload file", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open upload"})
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to seek upload"})
+		return
+	}
+
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(f, hasher), body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write chunk"})
+		return
+	}
+
+	if expected := c.GetHeader("X-Chunk-Checksum-Sha256"); expected != "" {
+		if hex.EncodeToString(hasher.Sum(nil)) != expected {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "chunk checksum mismatch"})
+			return
+		}
+	}
+
+	session.offset += written
+	c.Header("Upload-Offset", strconv.FormatInt(session.offset, 10))
+
+	if session.offset >= session.length {
+		h.completeUpload(session)
+		c.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) completeUpload(session *uploadSession) {
+	data, err := os.ReadFile(session.path)
+	if err != nil {
+		h.logger.Error("Failed to read completed upload", zap.String("upload_id", session.id), zap.Error(err))
+		return
+	}
+
+	jobID := h.processor.CreateVideoJob(data, session.filename, session.clientID)
+	h.logger.Info("Resumable upload completed, video job started",
+		zap.String("upload_id", session.id), zap.String("job_id", jobID))
+
+	go func() {
+		time.Sleep(time.Minute)
+		os.Remove(session.path)
+	}()
+}
+
+func (h *UploadHandler) session(id string) (*uploadSession, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	s, ok := h.uploads[id]
+	return s, ok
+}
+
+func generateUploadID(filename, clientIP string) string {
+	h := sha256.New()
+	h.Write([]byte(filename))
+	h.Write([]byte(clientIP))
+	h.Write([]byte(time.Now().String()))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -1,24 +1,40 @@
 package handlers
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/san-kum/motorcycle-cv/server/codec"
 	"github.com/san-kum/motorcycle-cv/server/models"
 	"github.com/san-kum/motorcycle-cv/server/processor"
 	"go.uber.org/zap"
 )
 
+// defaultMaxInFlightFrames bounds how many frames a single connection may
+// have in flight to the ML backend at once. See frameBacklog for why
+// exceeding it drops the oldest frame instead of queuing or rejecting the
+// newest.
+const defaultMaxInFlightFrames = 2
+
+// defaultReadTimeout is how long a connection may go without a client
+// message or pong before it's considered dead.
+const defaultReadTimeout = 60 * time.Second
+
 type WebSocketHandler struct {
-	processor *processor.FrameProcessor
-	logger    *zap.Logger
-	upgrader  websocket.Upgrader
+	processor         *processor.FrameProcessor
+	logger            *zap.Logger
+	upgrader          websocket.Upgrader
+	maxInFlightFrames int
+	readTimeout       atomic.Int64 // time.Duration nanoseconds; see SetReadTimeout
 }
 
 type ClientMessage struct {
@@ -33,7 +49,7 @@ type ServerMessage struct {
 }
 
 func NewWebSocketHandler(processor *processor.FrameProcessor, logger *zap.Logger) *WebSocketHandler {
-	return &WebSocketHandler{
+	h := &WebSocketHandler{
 		processor: processor,
 		logger:    logger,
 		upgrader: websocket.Upgrader{
@@ -42,8 +58,31 @@ func NewWebSocketHandler(processor *processor.FrameProcessor, logger *zap.Logger
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
+			Subprotocols: codec.SupportedSubprotocols,
 		},
+		maxInFlightFrames: defaultMaxInFlightFrames,
 	}
+	h.readTimeout.Store(int64(defaultReadTimeout))
+	return h
+}
+
+// SetMaxInFlightFrames overrides the default per-connection backpressure
+// limit (see frameBacklog). A value <= 0 disables the limit entirely.
+func (h *WebSocketHandler) SetMaxInFlightFrames(n int) {
+	h.maxInFlightFrames = n
+}
+
+// SetReadTimeout hot-reloads how long a connection may go without a
+// message or pong before it's considered dead, called by the
+// config.Manager watcher loop whenever ServerConfig.ReadTimeout changes.
+// Only new deadlines set after the call (the next pong or the next
+// connection) pick up the new value.
+func (h *WebSocketHandler) SetReadTimeout(d time.Duration) {
+	h.readTimeout.Store(int64(d))
+}
+
+func (h *WebSocketHandler) readTimeoutDuration() time.Duration {
+	return time.Duration(h.readTimeout.Load())
 }
 
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
@@ -55,12 +94,14 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	defer conn.Close()
 
 	clientIP := c.ClientIP()
-	h.logger.Info("WebSocket client connected", zap.String("client_ip", clientIP))
+	frameCodec := codec.ForSubprotocol(conn.Subprotocol())
+	h.logger.Info("WebSocket client connected",
+		zap.String("client_ip", clientIP), zap.String("codec", frameCodec.Name()))
 
 	conn.SetReadLimit(10 * 1024 * 1024)
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(h.readTimeoutDuration()))
 	conn.SetPongHandler(func(appData string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetReadDeadline(time.Now().Add(h.readTimeoutDuration()))
 		return nil
 	})
 
@@ -68,34 +109,63 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	defer ticker.Stop()
 
 	done := make(chan struct{})
+	var closeDoneOnce sync.Once
+	// closeDone is called from both the read loop's error path and
+	// pingRoutine's ping-failure path, which can race on a dead connection
+	// (a failed read and a failed ping can both happen at once) - sync.Once
+	// keeps the second caller from double-closing done and panicking.
+	closeDone := func() { closeDoneOnce.Do(func() { close(done) }) }
 	writeMu := &sync.Mutex{}
+	var lastSeq uint64
+
+	// connCtx is the parent of every in-flight frame's context for this
+	// connection, cancelled as soon as the connection is considered dead so
+	// AnalyzeFrame calls blocked on a slow ML backend don't outlive it -
+	// mirrors gonet's deadlineTimer.setDeadline: a single cancel signal that
+	// every derived timer/context downstream reacts to immediately.
+	connCtx, connCancel := context.WithCancel(context.Background())
+	defer connCancel()
+	backlog := newFrameBacklog(h.maxInFlightFrames)
+	var frameSeq uint64
 
-	go h.pingRoutine(conn, ticker, done, writeMu)
+	go h.pingRoutine(conn, ticker, done, closeDone, writeMu, connCancel)
 
 	for {
 		select {
 		case <-done:
 			return
 		default:
-			var message ClientMessage
-			err := conn.ReadJSON(&message)
+			msgType, data, err := conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					h.logger.Error("Websocket error: ", zap.Error(err))
 				}
-				close(done)
+				connCancel()
+				closeDone()
 				return
 			}
-			h.handleMessage(conn, &message, writeMu)
+
+			switch msgType {
+			case websocket.BinaryMessage:
+				h.processBinaryFrame(conn, connCtx, backlog, frameCodec, data, writeMu, &lastSeq)
+			case websocket.TextMessage:
+				var message ClientMessage
+				if err := json.Unmarshal(data, &message); err != nil {
+					h.logger.Error("Invalid client message", zap.Error(err))
+					h.sendError(conn, writeMu, "invalid message format")
+					continue
+				}
+				h.handleMessage(conn, connCtx, backlog, &message, writeMu, &frameSeq)
+			}
 		}
 	}
 
 }
 
-func (h *WebSocketHandler) handleMessage(conn *websocket.Conn, message *ClientMessage, writeMu *sync.Mutex) {
+func (h *WebSocketHandler) handleMessage(conn *websocket.Conn, connCtx context.Context, backlog *frameBacklog, message *ClientMessage, writeMu *sync.Mutex, frameSeq *uint64) {
 	switch message.Type {
 	case "frame":
-		h.processVideoFrame(conn, message, writeMu)
+		h.processVideoFrame(conn, connCtx, backlog, message, writeMu, frameSeq)
 	case "ping":
 		h.sendMessage(conn, writeMu, "pong", map[string]any{"timestamp": time.Now().Unix()})
 	case "config":
@@ -106,7 +176,7 @@ func (h *WebSocketHandler) handleMessage(conn *websocket.Conn, message *ClientMe
 	}
 }
 
-func (h *WebSocketHandler) processVideoFrame(conn *websocket.Conn, message *ClientMessage, writeMu *sync.Mutex) {
+func (h *WebSocketHandler) processVideoFrame(conn *websocket.Conn, connCtx context.Context, backlog *frameBacklog, message *ClientMessage, writeMu *sync.Mutex, frameSeq *uint64) {
 	imageData, err := h.extractImageData(message.Data)
 
 	if err != nil {
@@ -115,14 +185,80 @@ func (h *WebSocketHandler) processVideoFrame(conn *websocket.Conn, message *Clie
 		return
 	}
 
-	frameRequest := &models.FrameRequest{
+	*frameSeq++
+	h.dispatchFrame(conn, connCtx, backlog, &models.FrameRequest{
 		ImageData: imageData,
 		Timestamp: message.Timestamp,
 		ClientID:  h.getClientID(conn),
+	}, *frameSeq, writeMu)
+}
+
+// processBinaryFrame handles a binary WebSocket message carrying a raw
+// JPEG/H.264 frame: a 4-byte big-endian header length, the header encoded
+// with the codec negotiated at upgrade time, then the raw frame bytes. This
+// replaces extractImageData's base64-in-JSON decoding for clients that
+// negotiate it, saving the ~33% bandwidth/CPU overhead base64 adds per frame.
+func (h *WebSocketHandler) processBinaryFrame(conn *websocket.Conn, connCtx context.Context, backlog *frameBacklog, frameCodec codec.HeaderCodec, data []byte, writeMu *sync.Mutex, lastSeq *uint64) {
+	if len(data) < 4 {
+		h.sendError(conn, writeMu, "binary frame too short for header length")
+		return
+	}
+
+	headerLen := binary.BigEndian.Uint32(data[:4])
+	data = data[4:]
+	if uint64(len(data)) < uint64(headerLen) {
+		h.sendError(conn, writeMu, "binary frame truncated before end of header")
+		return
+	}
+
+	header, err := frameCodec.DecodeHeader(data[:headerLen])
+	if err != nil {
+		h.logger.Error("Failed to decode binary frame header", zap.Error(err))
+		h.sendError(conn, writeMu, "invalid frame header")
+		return
+	}
+	frameData := data[headerLen:]
+
+	if *lastSeq != 0 && header.Sequence > *lastSeq+1 {
+		h.logger.Warn("Dropped or out-of-order frames detected",
+			zap.Uint64("expected", *lastSeq+1), zap.Uint64("got", header.Sequence))
+	}
+	*lastSeq = header.Sequence
+
+	clientID := header.ClientID
+	if clientID == "" {
+		clientID = h.getClientID(conn)
 	}
+
+	h.dispatchFrame(conn, connCtx, backlog, &models.FrameRequest{
+		ImageData: frameData,
+		Timestamp: header.Timestamp,
+		ClientID:  clientID,
+	}, header.Sequence, writeMu)
+}
+
+// dispatchFrame hands frameRequest to the processor through backlog, which
+// bounds how many frames this connection may have outstanding at once (see
+// frameBacklog). Processing always runs in its own goroutine so a slow
+// frame can't stall the read loop above.
+func (h *WebSocketHandler) dispatchFrame(conn *websocket.Conn, connCtx context.Context, backlog *frameBacklog, frameRequest *models.FrameRequest, seq uint64, writeMu *sync.Mutex) {
+	ctx, release, droppedSeq, dropped := backlog.admit(connCtx, seq)
+	if dropped {
+		h.sendMessage(conn, writeMu, "dropped", map[string]any{"sequence": droppedSeq})
+		h.processor.RecordDrop(frameRequest.ClientID)
+	}
+
 	go func() {
-		result, err := h.processor.ProcessFrame(frameRequest)
+		defer release()
+
+		result, err := h.processor.ProcessFrame(ctx, frameRequest)
 		if err != nil {
+			if ctx.Err() != nil {
+				// Evicted by a newer frame (backlog full) or the connection
+				// closed; the client already learned about it via "dropped"
+				// or isn't listening anymore, so there's nothing to report.
+				return
+			}
 			h.logger.Error("Frame processing failed", zap.Error(err))
 			h.sendError(conn, writeMu, "Frame processing failed")
 			return
@@ -142,6 +278,63 @@ func (h *WebSocketHandler) processVideoFrame(conn *websocket.Conn, message *Clie
 	}()
 }
 
+// frameBacklog bounds how many frames a single connection may have in
+// flight to the ML backend at once. Without it a slow backend plus a fast
+// sender lets goroutines pile up unbounded; instead, once at capacity the
+// oldest in-flight frame is evicted (its context is cancelled, so
+// AnalyzeFrame returns ctx.Err() almost immediately) to make room for the
+// newest one - for a live feedback stream, a stale frame's result is
+// worth less than a fresh one arriving promptly.
+type frameBacklog struct {
+	mu       sync.Mutex
+	max      int
+	inFlight []*inFlightFrame
+}
+
+type inFlightFrame struct {
+	seq    uint64
+	cancel context.CancelFunc
+}
+
+// newFrameBacklog creates a backlog allowing up to max frames in flight at
+// once. max <= 0 disables the limit.
+func newFrameBacklog(max int) *frameBacklog {
+	return &frameBacklog{max: max}
+}
+
+// admit registers a new in-flight frame derived from parent, evicting the
+// oldest one first if already at capacity. The caller must invoke release
+// once the frame finishes processing, cancelled or not.
+func (b *frameBacklog) admit(parent context.Context, seq uint64) (ctx context.Context, release func(), droppedSeq uint64, dropped bool) {
+	b.mu.Lock()
+
+	if b.max > 0 && len(b.inFlight) >= b.max {
+		oldest := b.inFlight[0]
+		b.inFlight = b.inFlight[1:]
+		oldest.cancel()
+		droppedSeq, dropped = oldest.seq, true
+	}
+
+	frameCtx, cancel := context.WithCancel(parent)
+	entry := &inFlightFrame{seq: seq, cancel: cancel}
+	b.inFlight = append(b.inFlight, entry)
+	b.mu.Unlock()
+
+	release = func() {
+		b.mu.Lock()
+		for i, f := range b.inFlight {
+			if f == entry {
+				b.inFlight = append(b.inFlight[:i], b.inFlight[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+		cancel()
+	}
+
+	return frameCtx, release, droppedSeq, dropped
+}
+
 func (h *WebSocketHandler) extractImageData(dataURL string) ([]byte, error) {
 	if !strings.Contains(dataURL, ",") {
 		return nil, websocket.ErrBadHandshake
@@ -195,7 +388,7 @@ func (h *WebSocketHandler) sendError(conn *websocket.Conn, writeMu *sync.Mutex,
 	})
 }
 
-func (h *WebSocketHandler) pingRoutine(conn *websocket.Conn, ticker *time.Ticker, done chan struct{}, writeMu *sync.Mutex) {
+func (h *WebSocketHandler) pingRoutine(conn *websocket.Conn, ticker *time.Ticker, done <-chan struct{}, closeDone func(), writeMu *sync.Mutex, connCancel context.CancelFunc) {
 	for {
 		select {
 		case <-ticker.C:
@@ -205,7 +398,8 @@ func (h *WebSocketHandler) pingRoutine(conn *websocket.Conn, ticker *time.Ticker
 			writeMu.Unlock()
 			if err != nil {
 				h.logger.Error("Failed to send ping", zap.Error(err))
-				close(done)
+				connCancel()
+				closeDone()
 				return
 			}
 		case <-done:
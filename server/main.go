@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"log"
 	"net/http"
@@ -24,10 +29,12 @@ type Server struct {
 	router         *gin.Engine
 	logger         *zap.Logger
 	frameProcessor *processor.FrameProcessor
-	mlClient       *ml.Client
+	mlBackend      ml.Backend
 	cache          cache.Cache
 	rateLimiter    *middleware.RateLimiter
+	reproducer     *middleware.Reproducer
 	config         *config.Config
+	configManager  *config.Manager
 }
 
 func main() {
@@ -118,6 +125,18 @@ func main() {
 		}
 	}
 
+	// Shutdown reproducer
+	if server.reproducer != nil {
+		if err := server.reproducer.Close(); err != nil {
+			logger.Error("Failed to close reproducer", zap.Error(err))
+		}
+	}
+
+	// Shutdown config manager
+	if server.configManager != nil {
+		server.configManager.Close()
+	}
+
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
@@ -133,14 +152,7 @@ func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 
 	// Try Redis first, fallback to memory cache
 	if cfg.Redis.Host != "" {
-		cacheInstance, err = cache.NewRedisCache(
-			cfg.Redis.Host,
-			cfg.Redis.Port,
-			cfg.Redis.Password,
-			cfg.Redis.DB,
-			5*time.Minute, // Default TTL
-			logger,
-		)
+		cacheInstance, err = cache.NewRedisCacheWithOptions(redisOptionsFromConfig(cfg.Redis), 5*time.Minute, logger)
 		if err != nil {
 			logger.Warn("Failed to connect to Redis, using memory cache", zap.Error(err))
 			cacheInstance = cache.NewMemoryCache(1000, 5*time.Minute, logger)
@@ -149,30 +161,72 @@ func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 		cacheInstance = cache.NewMemoryCache(1000, 5*time.Minute, logger)
 	}
 
-	// Initialize ML client
-	mlClient, err := ml.NewClient(cfg.ML.BaseURL, logger)
+	// Initialize the ML backend. The processor and WebSocket handler only
+	// see ml.Backend, so swapping this doesn't touch either of them.
+	mlBackend, err := newMLBackend(cfg.ML, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ML client: %w", err)
+		return nil, fmt.Errorf("failed to create ML backend: %w", err)
 	}
 
 	// Initialize frame processor
-	frameProcessor := processor.NewFrameProcessor(mlClient, cacheInstance, logger)
-
-	// Initialize rate limiter
-	rateLimiter := middleware.NewRateLimiter(
-		cfg.Security.RateLimitRPS,
-		cfg.Security.RateLimitBurst,
-		logger,
-	)
+	frameProcessor := processor.NewFrameProcessor(mlBackend, cacheInstance, logger)
+
+	// Initialize rate limiter, sharing bucket state across replicas via
+	// Redis when the cache landed on it above.
+	var rateLimiter *middleware.RateLimiter
+	if redisCache, ok := cacheInstance.(*cache.RedisCache); ok {
+		rateLimiter = middleware.NewRedisRateLimiter(
+			redisCache,
+			cfg.Security.RateLimitRPS,
+			cfg.Security.RateLimitBurst,
+			logger,
+		)
+	} else {
+		rateLimiter = middleware.NewRateLimiter(
+			cfg.Security.RateLimitRPS,
+			cfg.Security.RateLimitBurst,
+			logger,
+		)
+	}
 
 	// Initialize authentication middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.Security.JWTSecretKey, logger)
+	signingKeys, err := buildKeyProvider(cfg.Security, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWT key provider: %w", err)
+	}
+	authMiddleware := middleware.NewAuthMiddleware(signingKeys, cacheInstance, logger)
+
+	// Initialize AWS SigV4 auth as an alternative to JWT, e.g. for fleet
+	// management systems that already sign S3 requests. Only enforced on
+	// routes it's attached to, and only if access keys are configured.
+	var sigV4Auth *middleware.SigV4Auth
+	if len(cfg.Security.SigV4Keys) > 0 {
+		sigV4Auth = middleware.NewSigV4Auth(cfg.Security.SigV4Keys, logger)
+	}
+
+	// Initialize the request/crash reproducer
+	reproducer, err := middleware.NewReproducer(cfg.Server.UploadDir+"/captures", logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reproducer: %w", err)
+	}
+	frameProcessor.SetCrashRecorder(reproducer)
+
+	// Wire up the live-inspection debug endpoints (GET /debug/live,
+	// GET /debug/stats). DebugHandler.Observer() is the single
+	// PipelineObserver registered here; FrameProcessor bridges ml.Client's
+	// AnalyzeEvent callbacks into it too, so this one call covers both
+	// layers (see processor.FrameProcessor.SetObserver).
+	debugHandler := handlers.NewDebugHandler(logger)
+	frameProcessor.SetObserver(debugHandler.Observer())
 
 	// Setup router
 	router := gin.New()
 
-	// Add middleware
+	// Add middleware. The reproducer sits outside gin.Recovery() so it sees
+	// the final status of a request whether it failed normally or via a
+	// panic Recovery already turned into a 500.
 	router.Use(middleware.RequestLogger(logger))
+	router.Use(reproducer.Middleware())
 	router.Use(gin.Recovery())
 	router.Use(middleware.SecurityHeaders())
 	router.Use(middleware.CORS(cfg.Security.AllowedOrigins))
@@ -183,25 +237,161 @@ func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 	// Initialize handlers
 	wsHandler := handlers.NewWebSocketHandler(frameProcessor, logger)
 	streamHandler := handlers.NewStreamHandler(frameProcessor, logger)
+	uploadHandler := handlers.NewUploadHandler(frameProcessor, logger, cfg.Server.UploadDir, cfg.Server.MaxUploadSize)
+	replayHandler := handlers.NewReplayHandler(reproducer, router)
+
+	// Cache-backed rate limiting for routes that need a specific algorithm
+	// or a per-role budget (see middleware.CacheRateLimiter), layered on
+	// top of rateLimiter's coarse-grained default-RPS limiting below.
+	cacheRateLimiter := middleware.NewCacheRateLimiter(cacheInstance, logger)
+
+	// Config hot-reload: re-reading env vars can't itself notice a change
+	// (nothing short of a restart re-execs the process into a new
+	// environment), so EnvProvider is paired with pollInterval=0 here and
+	// reload instead comes from configHandler's admin API calling
+	// configManager.DoLockedAction below. The poll-driven path
+	// (pollInterval > 0) is for FileProvider/future Consul-etcd providers.
+	configManager, err := config.NewManager(config.EnvProvider{}, 0, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config manager: %w", err)
+	}
+	go watchConfig(configManager.Subscribe(), mlBackend, rateLimiter, wsHandler, logger)
+	configHandler := handlers.NewConfigHandler(configManager, logger)
 
 	// Setup routes
-	setupRoutes(router, wsHandler, streamHandler, authMiddleware, rateLimiter)
+	setupRoutes(router, wsHandler, streamHandler, uploadHandler, replayHandler, debugHandler, configHandler, authMiddleware, sigV4Auth, rateLimiter, cacheRateLimiter)
 
 	return &Server{
 		router:         router,
 		logger:         logger,
 		frameProcessor: frameProcessor,
-		mlClient:       mlClient,
+		mlBackend:      mlBackend,
 		cache:          cacheInstance,
 		rateLimiter:    rateLimiter,
+		reproducer:     reproducer,
 		config:         cfg,
+		configManager:  configManager,
 	}, nil
 }
 
-func setupRoutes(router *gin.Engine, wsHandler *handlers.WebSocketHandler, streamHandler *handlers.StreamHandler, auth *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter) {
+// watchConfig applies hot-reloadable settings to each subscribed subsystem
+// whenever configManager broadcasts an update, so ML retry/timeout tuning,
+// rate limits, and the WebSocket read timeout can change without a
+// restart. mlBackend is only reconfigured if it's the HTTP ml.Client -
+// GRPCBackend and ONNXBackend don't expose equivalent settings to reload.
+func watchConfig(updates config.Watcher, mlBackend ml.Backend, rateLimiter *middleware.RateLimiter, wsHandler *handlers.WebSocketHandler, logger *zap.Logger) {
+	for cfg := range updates {
+		if client, ok := mlBackend.(*ml.Client); ok {
+			client.ApplyConfig(ml.ClientConfig{
+				Timeout:             cfg.ML.Timeout,
+				MaxRetries:          cfg.ML.MaxRetries,
+				RetryDelay:          cfg.ML.RetryDelay,
+				HealthCheckInterval: cfg.ML.HealthCheckInterval,
+			}, ml.RequestEncoding(cfg.ML.RequestEncoding))
+		}
+
+		rateLimiter.UpdateLimits(cfg.Security.RateLimitRPS, cfg.Security.RateLimitBurst)
+		wsHandler.SetReadTimeout(cfg.Server.ReadTimeout)
+
+		logger.Info("Applied reloaded config to subsystems")
+	}
+}
+
+// buildKeyProvider loads a JWT signing key from cfg.JWTPrivateKeyPEM
+// according to cfg.JWTAlgorithm, or generates an ephemeral Ed25519 key if
+// none is configured (local development, or a deployment that hasn't set
+// one up yet).
+func buildKeyProvider(cfg config.SecurityConfig, logger *zap.Logger) (middleware.KeyProvider, error) {
+	if cfg.JWTPrivateKeyPEM == "" {
+		return middleware.NewEphemeralKeyProvider(logger)
+	}
+
+	block, _ := pem.Decode([]byte(cfg.JWTPrivateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode JWT_PRIVATE_KEY_PEM as PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT private key: %w", err)
+	}
+
+	switch cfg.JWTAlgorithm {
+	case "rsa":
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("JWT_ALGORITHM=rsa but configured key is not an RSA key")
+		}
+		return middleware.NewRSAKeyProvider(cfg.JWTKeyID, rsaKey), nil
+	case "ed25519":
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("JWT_ALGORITHM=ed25519 but configured key is not an Ed25519 key")
+		}
+		return middleware.NewEdDSAKeyProvider(cfg.JWTKeyID, edKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q (want \"rsa\" or \"ed25519\")", cfg.JWTAlgorithm)
+	}
+}
+
+// redisOptionsFromConfig translates config.RedisConfig into cache.RedisOptions,
+// selecting Sentinel or Cluster mode when the corresponding addresses are
+// configured and falling back to a plain single-node connection otherwise.
+func redisOptionsFromConfig(cfg config.RedisConfig) cache.RedisOptions {
+	opts := cache.RedisOptions{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
+	}
+
+	if cfg.TLSEnabled {
+		opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	switch {
+	case len(cfg.ClusterAddrs) > 0:
+		opts.Cluster = &cache.ClusterOptions{Addrs: cfg.ClusterAddrs}
+	case len(cfg.SentinelAddrs) > 0:
+		opts.Sentinel = &cache.SentinelOptions{
+			MasterName: cfg.SentinelMasterName,
+			Addrs:      cfg.SentinelAddrs,
+		}
+	}
+
+	return opts
+}
+
+// newMLBackend constructs the ml.Backend selected by cfg.Backend ("http" by
+// default). The WebSocket handler and processor only depend on ml.Backend,
+// so adding a new backend only means adding a case here.
+func newMLBackend(cfg config.MLConfig, logger *zap.Logger) (ml.Backend, error) {
+	switch cfg.Backend {
+	case "grpc":
+		return ml.NewGRPCBackend(cfg.GRPCAddress, logger)
+	case "onnx":
+		return ml.NewONNXBackend(cfg.ONNXModelPath, logger)
+	case "http", "":
+		client, err := ml.NewClient(cfg.BaseURL, logger)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRequestEncoding(ml.RequestEncoding(cfg.RequestEncoding))
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown ML backend %q", cfg.Backend)
+	}
+}
+
+func setupRoutes(router *gin.Engine, wsHandler *handlers.WebSocketHandler, streamHandler *handlers.StreamHandler, uploadHandler *handlers.UploadHandler, replayHandler *handlers.ReplayHandler, debugHandler *handlers.DebugHandler, configHandler *handlers.ConfigHandler, auth *middleware.AuthMiddleware, sigV4Auth *middleware.SigV4Auth, rateLimiter *middleware.RateLimiter, cacheRateLimiter *middleware.CacheRateLimiter) {
 	// Health check (no auth required)
 	router.GET("/health", middleware.HealthCheck())
 
+	// JWKS, so other services can validate tokens this process issued
+	// without sharing a secret out of band.
+	router.GET("/.well-known/jwks.json", auth.JWKSHandler)
+
 	// WebSocket endpoint (rate limited)
 	router.GET("/ws", rateLimiter.RateLimit(), wsHandler.HandleWebSocket)
 
@@ -210,32 +400,77 @@ func setupRoutes(router *gin.Engine, wsHandler *handlers.WebSocketHandler, strea
 	{
 		// Public endpoints
 		api.GET("/health", middleware.HealthCheck())
+		api.POST("/auth/refresh", auth.RefreshHandler)
 
 		// Protected endpoints
 		protected := api.Group("/")
 		protected.Use(rateLimiter.RateLimit())
 		{
-			// Frame analysis (rate limited)
-			protected.POST("/analyze-frame", streamHandler.ProcessFrame)
+			// Frame analysis (rate limited). A separate group so SigV4Auth
+			// can be required here without touching the rest of the API -
+			// lets fleet-management systems sign with their existing
+			// access-key/secret-key pair instead of doing a JWT round-trip.
+			frameIngest := protected.Group("/")
+			if sigV4Auth != nil {
+				frameIngest.Use(sigV4Auth.RequireAuth())
+			}
+			// OptionalAuth sets user_id/role from a bearer token when one is
+			// present, which KeyByUser and RoleLimits below key on.
+			frameIngest.Use(auth.OptionalAuth())
+			// A tighter, per-user token-bucket budget on top of
+			// rateLimiter's coarse per-IP default, so one authenticated
+			// user can't starve others even from behind a shared NAT/proxy
+			// IP; "admin" gets a larger budget than everyone else.
+			frameIngest.Use(cacheRateLimiter.Limit(middleware.RateLimitRule{
+				Algorithm: middleware.TokenBucket,
+				Limit:     20,
+				Window:    time.Second,
+				KeyFunc:   middleware.KeyByUser,
+				RoleLimits: map[string]int{
+					"admin": 100,
+				},
+			}))
+			frameIngest.POST("/analyze-frame", streamHandler.ProcessFrame)
+			frameIngest.POST("/analyze-frame-stream", streamHandler.AnalyzeFrameStream)
 
 			// Statistics (rate limited)
 			protected.GET("/stats", streamHandler.GetStats)
+			protected.GET("/stats/human", streamHandler.GetHumanStats)
 
 			// Video upload (rate limited)
 			protected.POST("/upload-video", streamHandler.UploadVideo)
 			protected.GET("/video-job/:job_id", streamHandler.GetVideoJobStatus)
+			protected.GET("/video-job/:job_id/manifest.mpd", streamHandler.GetVideoManifest)
+			protected.GET("/video-job/:job_id/master.m3u8", streamHandler.GetVideoMasterPlaylist)
+			protected.GET("/video-job/:job_id/:name", streamHandler.GetVideoSegment)
+
+			// Resumable chunked upload (tus-style)
+			protected.POST("/uploads", uploadHandler.CreateUpload)
+			protected.HEAD("/uploads/:id", uploadHandler.HeadUpload)
+			protected.PATCH("/uploads/:id", uploadHandler.PatchUpload)
 		}
 
 		// Admin endpoints (require authentication)
 		admin := api.Group("/admin")
 		admin.Use(auth.RequireAuth())
-		admin.Use(auth.RequireRole("admin"))
+		admin.Use(auth.RequireRoles("admin"))
 		{
 			admin.GET("/stats", streamHandler.GetStats)
 			admin.GET("/cache-stats", func(c *gin.Context) {
 				// This would need to be implemented in the handler
 				c.JSON(http.StatusOK, gin.H{"message": "Cache stats endpoint"})
 			})
+			admin.POST("/replay", replayHandler.Replay)
+
+			// Live frame-pipeline inspection (see handlers.DebugHandler).
+			admin.GET("/debug/live", debugHandler.HandleLive)
+			admin.GET("/debug/stats", debugHandler.GetStats)
+
+			// Hot-reload a subset of config at runtime (see
+			// handlers.ConfigHandler); fingerprint-guarded so this can't race
+			// a concurrent FileProvider-driven reload.
+			admin.GET("/config", configHandler.GetConfig)
+			admin.PATCH("/config", configHandler.UpdateConfig)
 		}
 	}
 
@@ -1,57 +1,152 @@
 package middleware
 
 import (
-	"crypto/hmac"
+	"crypto/ed25519"
 	"crypto/rand"
-	"crypto/sha256"
+	"crypto/rsa"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/san-kum/motorcycle-cv/server/cache"
 	"go.uber.org/zap"
 )
 
+const (
+	issuer = "motorcycle-cv"
+
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// refreshKeyPrefix namespaces refresh-token records in Cache, keyed by
+	// jti, so a token can be revoked (or rotated out on use) by deleting
+	// its key rather than maintaining a separate denylist.
+	refreshKeyPrefix = "auth:refresh:"
+)
+
+// Claims are the JWT claims AuthMiddleware issues and validates. Embedding
+// jwt.RegisteredClaims gives every token iss/sub/aud/exp/nbf/iat/jti for
+// free; UserID/Username/Role/Scopes are this app's own additions.
 type Claims struct {
-	UserID    string    `json:"user_id"`
-	Username  string    `json:"username"`
-	Role      string    `json:"role"`
-	ExpiresAt time.Time `json:"expires_at"`
-	IssuedAt  time.Time `json:"issued_at"`
+	jwt.RegisteredClaims
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Scopes   []string `json:"scopes,omitempty"`
 }
 
-type AuthMiddleware struct {
-	secretKey []byte
-	logger    *zap.Logger
+// KeyProvider resolves the key material AuthMiddleware signs and validates
+// tokens with. SigningKey returns the one key new tokens are signed with,
+// identified by a kid written into the JWT header; PublicKeys returns
+// every kid that should still validate, so a rotated-out key keeps
+// verifying already-issued tokens until they expire. Implementations can
+// swap out what PublicKeys returns at runtime to support rotation without
+// restarting the process.
+type KeyProvider interface {
+	SigningKey() (kid string, key interface{}, method jwt.SigningMethod)
+	PublicKeys() map[string]interface{}
+}
+
+// StaticKeyProvider is a KeyProvider over a single, fixed key pair -
+// appropriate for a single-replica deployment or local development.
+// Deployments that need rotation should implement KeyProvider against
+// whatever key-management system issues their keys instead.
+type StaticKeyProvider struct {
+	kid    string
+	key    interface{}
+	public interface{}
+	method jwt.SigningMethod
+}
+
+// NewRSAKeyProvider builds a StaticKeyProvider that signs with RS256.
+func NewRSAKeyProvider(kid string, privateKey *rsa.PrivateKey) *StaticKeyProvider {
+	return &StaticKeyProvider{
+		kid:    kid,
+		key:    privateKey,
+		public: &privateKey.PublicKey,
+		method: jwt.SigningMethodRS256,
+	}
 }
 
-func NewAuthMiddleware(secretKey string, logger *zap.Logger) *AuthMiddleware {
-	if secretKey == "" {
-		key := make([]byte, 32)
-		rand.Read(key)
-		secretKey = base64.StdEncoding.EncodeToString(key)
-		logger.Warn("No secret key provided, generated random key", zap.String("key", secretKey))
+// NewEdDSAKeyProvider builds a StaticKeyProvider that signs with EdDSA
+// (Ed25519).
+func NewEdDSAKeyProvider(kid string, privateKey ed25519.PrivateKey) *StaticKeyProvider {
+	return &StaticKeyProvider{
+		kid:    kid,
+		key:    privateKey,
+		public: privateKey.Public(),
+		method: jwt.SigningMethodEdDSA,
 	}
+}
 
-	return &AuthMiddleware{
-		secretKey: []byte(secretKey),
-		logger:    logger,
+// NewEphemeralKeyProvider generates a random Ed25519 key pair, for
+// deployments that haven't configured a persistent signing key. Tokens
+// signed with it won't validate after a restart or on another replica, so
+// this logs a warning the same way the old hand-rolled secret generation
+// did.
+func NewEphemeralKeyProvider(logger *zap.Logger) (*StaticKeyProvider, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral signing key: %w", err)
 	}
+
+	logger.Warn("No JWT signing key configured, generated an ephemeral Ed25519 key - tokens won't survive a restart or validate on other replicas")
+
+	return &StaticKeyProvider{
+		kid:    "ephemeral",
+		key:    private,
+		public: public,
+		method: jwt.SigningMethodEdDSA,
+	}, nil
+}
+
+func (p *StaticKeyProvider) SigningKey() (string, interface{}, jwt.SigningMethod) {
+	return p.kid, p.key, p.method
+}
+
+func (p *StaticKeyProvider) PublicKeys() map[string]interface{} {
+	return map[string]interface{}{p.kid: p.public}
+}
+
+// refreshRecord is what IssueRefreshToken stores in Cache under
+// refreshKeyPrefix+jti, so RefreshHandler can mint a new access token
+// without trusting claims the client could forge if the cache lookup
+// weren't there to confirm the token hasn't been revoked.
+type refreshRecord struct {
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Scopes   []string `json:"scopes"`
+}
+
+type AuthMiddleware struct {
+	keys   KeyProvider
+	cache  cache.Cache
+	logger *zap.Logger
+}
+
+func NewAuthMiddleware(keys KeyProvider, cacheInstance cache.Cache, logger *zap.Logger) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, cache: cacheInstance, logger: logger}
 }
 
+// RequireAuth validates the bearer token and, on success, sets
+// user_id/username/role/scopes in the gin context for downstream handlers
+// and middleware (RequireRoles, RequireScope, KeyByUser).
 func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := a.extractToken(c)
-		if token == "" {
+		tokenString := a.extractToken(c)
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
 			c.Abort()
 			return
 		}
 
-		claims, err := a.validateToken(token)
+		claims, err := a.validateToken(tokenString)
 		if err != nil {
 			a.logger.Warn("Invalid token", zap.Error(err), zap.String("client_ip", c.ClientIP()))
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -62,11 +157,14 @@ func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("scopes", claims.Scopes)
 		c.Next()
 	}
 }
 
-func (a *AuthMiddleware) RequireRole(requiredRole string) gin.HandlerFunc {
+// RequireRoles allows the request through if the authenticated role
+// matches any of allowed, generalizing the old single-role RequireRole.
+func (a *AuthMiddleware) RequireRoles(allowed ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
 		if !exists {
@@ -75,63 +173,237 @@ func (a *AuthMiddleware) RequireRole(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		if role.(string) != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		roleName, _ := role.(string)
+		for _, candidate := range allowed {
+			if roleName == candidate {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// RequireScope allows the request through only if the authenticated
+// token's scopes include every scope in required.
+func (a *AuthMiddleware) RequireScope(required ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopesVal, exists := c.Get("scopes")
+		if !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Scope information not found"})
 			c.Abort()
 			return
 		}
 
+		have := make(map[string]struct{})
+		if scopes, ok := scopesVal.([]string); ok {
+			for _, s := range scopes {
+				have[s] = struct{}{}
+			}
+		}
+
+		for _, need := range required {
+			if _, ok := have[need]; !ok {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope"})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
+// OptionalAuth sets user_id/username/role/scopes when a valid token is
+// present but, unlike RequireAuth, lets the request through either way -
+// for routes whose behavior only varies by caller identity.
 func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := a.extractToken(c)
-		if token != "" {
-			claims, err := a.validateToken(token)
-			if err == nil {
-				c.Set("user_id", claims.UserID)
-				c.Set("username", claims.Username)
-				c.Set("role", claims.Role)
-			}
+		tokenString := a.extractToken(c)
+		if tokenString == "" {
+			c.Next()
+			return
+		}
+
+		claims, err := a.validateToken(tokenString)
+		if err == nil {
+			c.Set("user_id", claims.UserID)
+			c.Set("username", claims.Username)
+			c.Set("role", claims.Role)
+			c.Set("scopes", claims.Scopes)
 		}
 		c.Next()
 	}
 }
 
-func (a *AuthMiddleware) GenerateToken(userID, username, role string, duration time.Duration) (string, error) {
-	now := time.Now()
-	claims := Claims{
-		UserID:    userID,
-		Username:  username,
-		Role:      role,
-		ExpiresAt: now.Add(duration),
-		IssuedAt:  now,
+// GenerateAccessToken issues a short-lived access token for userID. Kept
+// as a direct entry point for callers that mint tokens without going
+// through the refresh flow (an admin CLI, a test harness); the
+// client-facing flow is RefreshHandler exchanging a refresh token for a
+// fresh access/refresh pair.
+func (a *AuthMiddleware) GenerateAccessToken(userID, username, role string, scopes []string) (string, error) {
+	token, _, err := a.issueToken(userID, username, role, scopes, accessTokenTTL)
+	return token, err
+}
+
+// IssueRefreshToken mints a long-lived refresh token and records it in
+// Cache under its jti so RefreshHandler can later confirm it hasn't been
+// revoked, and so revoking a session is just deleting that key.
+func (a *AuthMiddleware) IssueRefreshToken(c *gin.Context, userID, username, role string, scopes []string) (string, error) {
+	token, jti, err := a.issueToken(userID, username, role, scopes, refreshTokenTTL)
+	if err != nil {
+		return "", err
+	}
+
+	record := refreshRecord{UserID: userID, Username: username, Role: role, Scopes: scopes}
+	if err := a.cache.SetWithTTL(c.Request.Context(), refreshKeyPrefix+jti, record, refreshTokenTTL); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
-	header := map[string]string{
-		"typ": "JWT",
-		"alg": "HS256",
+	return token, nil
+}
+
+// RevokeRefreshToken deletes a refresh token's Cache record by jti, so any
+// future refresh attempt with it fails even though the token itself
+// hasn't expired yet.
+func (a *AuthMiddleware) RevokeRefreshToken(c *gin.Context, jti string) error {
+	return a.cache.Delete(c.Request.Context(), refreshKeyPrefix+jti)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshHandler exchanges a valid, unrevoked refresh token for a new
+// access/refresh pair. The used refresh token is deleted before the new
+// one is issued (rotation), so a leaked refresh token is only usable once
+// before the legitimate holder's next refresh invalidates it.
+func (a *AuthMiddleware) RefreshHandler(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
 	}
 
-	headerJSON, err := json.Marshal(header)
+	claims, err := a.validateToken(req.RefreshToken)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	var record refreshRecord
+	if err := a.cache.Get(c.Request.Context(), refreshKeyPrefix+claims.ID, &record); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
 	}
+	a.cache.Delete(c.Request.Context(), refreshKeyPrefix+claims.ID)
 
-	claimsJSON, err := json.Marshal(claims)
+	accessToken, err := a.GenerateAccessToken(record.UserID, record.Username, record.Role, record.Scopes)
 	if err != nil {
-		return "", err
+		a.logger.Error("Failed to issue access token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue access token"})
+		return
 	}
-	headerEncoded := base64.RawURLEncoding.EncodeToString(headerJSON)
-	payloadEncoded := base64.RawURLEncoding.EncodeToString(claimsJSON)
 
-	message := headerEncoded + "." + payloadEncoded
-	signature := a.createSignature(message)
+	refreshToken, err := a.IssueRefreshToken(c, record.UserID, record.Username, record.Role, record.Scopes)
+	if err != nil {
+		a.logger.Error("Failed to issue refresh token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue refresh token"})
+		return
+	}
 
-	token := message + "." + signature
-	return token, nil
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// jwk and jwksResponse mirror the standard JWK Set shape (RFC 7517) well
+// enough for the RSA and OKP/Ed25519 keys this package issues.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type jwksResponse struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKSHandler serves the public half of every key KeyProvider knows about
+// at /.well-known/jwks.json, so other services can validate tokens this
+// process issued without sharing a secret out of band.
+func (a *AuthMiddleware) JWKSHandler(c *gin.Context) {
+	resp := jwksResponse{}
+
+	for kid, key := range a.keys.PublicKeys() {
+		switch pub := key.(type) {
+		case *rsa.PublicKey:
+			resp.Keys = append(resp.Keys, jwk{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			resp.Keys = append(resp.Keys, jwk{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// issueToken signs a Claims token for ttl, returning the signed token and
+// the jti it was issued with.
+func (a *AuthMiddleware) issueToken(userID, username, role string, scopes []string, ttl time.Duration) (signed string, jti string, err error) {
+	jti, err = randomID()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{issuer},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+		UserID:   userID,
+		Username: username,
+		Role:     role,
+		Scopes:   scopes,
+	}
+
+	kid, key, method := a.keys.SigningKey()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	signed, err = token.SignedString(key)
+	return signed, jti, err
 }
 
 func (a *AuthMiddleware) extractToken(c *gin.Context) string {
@@ -140,7 +412,7 @@ func (a *AuthMiddleware) extractToken(c *gin.Context) string {
 		return ""
 	}
 
-	parts := strings.Split(authHeader, " ")
+	parts := strings.SplitN(authHeader, " ", 2)
 	if len(parts) != 2 || parts[0] != "Bearer" {
 		return ""
 	}
@@ -148,37 +420,34 @@ func (a *AuthMiddleware) extractToken(c *gin.Context) string {
 	return parts[1]
 }
 
-func (a *AuthMiddleware) validateToken(token string) (*Claims, error) {
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid token format")
-	}
-
-	message := parts[0] + "." + parts[1]
-	expectedSignature := a.createSignature(message)
-	if !hmac.Equal([]byte(parts[2]), []byte(expectedSignature)) {
-		return nil, fmt.Errorf("invalid signature")
-	}
+// validateToken parses and verifies tokenString, resolving the
+// verification key from KeyProvider.PublicKeys() by the token's kid
+// header so rotated keys keep validating tokens issued before rotation.
+func (a *AuthMiddleware) validateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
 
-	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := a.keys.PublicKeys()[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{"RS256", "EdDSA"}), jwt.WithIssuer(issuer), jwt.WithAudience(issuer))
 	if err != nil {
-		return nil, fmt.Errorf("invalid payload encoding")
-	}
-
-	var claims Claims
-	if err := json.Unmarshal(payload, &claims); err != nil {
-		return nil, fmt.Errorf("invalid payload format")
+		return nil, err
 	}
-
-	if time.Now().After(claims.ExpiresAt) {
-		return nil, fmt.Errorf("token expired")
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
 	}
 
-	return &claims, nil
+	return claims, nil
 }
 
-func (a *AuthMiddleware) createSignature(message string) string {
-	h := hmac.New(sha256.New, a.secretKey)
-	h.Write([]byte(message))
-	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
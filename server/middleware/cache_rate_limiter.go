@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/cache"
+	"go.uber.org/zap"
+)
+
+// RateLimitAlgorithm selects how CacheRateLimiter.Limit enforces a
+// RateLimitRule.
+type RateLimitAlgorithm string
+
+const (
+	FixedWindow      RateLimitAlgorithm = "fixed_window"
+	SlidingWindowLog RateLimitAlgorithm = "sliding_window_log"
+	TokenBucket      RateLimitAlgorithm = "token_bucket"
+)
+
+// RateLimitKeyFunc extracts the identity a RateLimitRule is keyed on from a
+// request - an IP, an authenticated user ID, or an API key.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP keys on the client's IP, for anonymous or unauthenticated routes.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser keys on AuthMiddleware's "user_id" context value, falling back
+// to KeyByIP for requests that never authenticated (e.g. OptionalAuth).
+func KeyByUser(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(string); ok && id != "" {
+			return "user:" + id
+		}
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey keys on the X-Api-Key request header, falling back to
+// KeyByIP for requests that don't send one.
+func KeyByAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-Api-Key"); key != "" {
+		return "apikey:" + key
+	}
+	return KeyByIP(c)
+}
+
+// RateLimitRule configures one CacheRateLimiter.Limit middleware instance.
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	KeyFunc   RateLimitKeyFunc
+
+	// RoleLimits overrides Limit for specific roles (AuthMiddleware sets
+	// "role" in the gin context on RequireAuth/OptionalAuth), so
+	// authenticated callers can get a higher budget than anonymous ones.
+	RoleLimits map[string]int
+}
+
+// CacheRateLimiter enforces RateLimitRules using cache.Cache's
+// Increment/IncrementWithTTL/Get/SetWithTTL as the shared counter store, so
+// the same middleware works distributed across replicas whenever cache is
+// Redis-backed (or TieredCache, or EtcdCache) without a
+// backend-specific implementation. This is unrelated to the older
+// RateLimiter/memoryBackend/redisBackend pair in rate_limit.go, which stays
+// in place for the coarse-grained default-RPS limiting applied to every
+// protected route; CacheRateLimiter is for routes that need a specific
+// algorithm or per-role budget, like frameIngest in main.go.
+type CacheRateLimiter struct {
+	cache  cache.Cache
+	logger *zap.Logger
+}
+
+func NewCacheRateLimiter(cache cache.Cache, logger *zap.Logger) *CacheRateLimiter {
+	return &CacheRateLimiter{cache: cache, logger: logger}
+}
+
+// Limit returns Gin middleware enforcing rule. A cache backend error fails
+// open (the request is allowed through, same as RateLimiter.enforce does),
+// since a rate limiter being unreachable shouldn't take the whole API down
+// with it.
+func (rl *CacheRateLimiter) Limit(rule RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rule.KeyFunc(c)
+		limit := rl.effectiveLimit(c, rule)
+
+		var (
+			allowed   bool
+			remaining int
+			resetAt   time.Time
+			err       error
+		)
+
+		switch rule.Algorithm {
+		case SlidingWindowLog:
+			allowed, remaining, resetAt, err = rl.allowSlidingWindowLog(c, key, limit, rule.Window)
+		case TokenBucket:
+			allowed, remaining, resetAt, err = rl.allowTokenBucket(c, key, limit, rule.Window)
+		default:
+			allowed, remaining, resetAt, err = rl.allowFixedWindow(c, key, limit, rule.Window)
+		}
+
+		if err != nil {
+			rl.logger.Warn("Cache rate limiter backend error, allowing request", zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+		if !allowed {
+			retryAfter := int(time.Until(resetAt).Round(time.Second).Seconds())
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"retry_after": retryAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (rl *CacheRateLimiter) effectiveLimit(c *gin.Context, rule RateLimitRule) int {
+	if role, exists := c.Get("role"); exists {
+		if roleName, ok := role.(string); ok {
+			if override, ok := rule.RoleLimits[roleName]; ok {
+				return override
+			}
+		}
+	}
+	return rule.Limit
+}
+
+// allowFixedWindow counts requests in the current Window-sized bucket
+// (keyed by the bucket's truncated start time, so it naturally rolls over
+// rather than sliding forward on every request the way a plain
+// IncrementWithTTL-refreshes-its-own-TTL counter would).
+func (rl *CacheRateLimiter) allowFixedWindow(c *gin.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetAt time.Time, err error) {
+	now := time.Now()
+	bucketStart := now.Truncate(window)
+	resetAt = bucketStart.Add(window)
+
+	cacheKey := fmt.Sprintf("ratelimit:fixed:%s:%d", key, bucketStart.Unix())
+	count, err := rl.cache.IncrementWithTTL(c.Request.Context(), cacheKey, window)
+	if err != nil {
+		return false, 0, resetAt, err
+	}
+
+	remaining = limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return int(count) <= limit, remaining, resetAt, nil
+}
+
+// allowSlidingWindowLog keeps a JSON-encoded slice of request timestamps
+// within the last Window and rejects once it holds limit or more. Storing
+// the log as a single Get/SetWithTTL round trip (rather than a real sorted
+// set with per-entry atomic trims) means two concurrent requests against a
+// non-Redis cache.Cache can race the read-modify-write - acceptable for the
+// Cache-backend-agnostic contract this type promises, same tradeoff
+// RedisCache.EvalTokenBucket historically made before it got a real Lua
+// script.
+func (rl *CacheRateLimiter) allowSlidingWindowLog(c *gin.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetAt time.Time, err error) {
+	ctx := c.Request.Context()
+	now := time.Now()
+	cacheKey := "ratelimit:sliding:" + key
+
+	var timestamps []int64
+	if getErr := rl.cache.Get(ctx, cacheKey, &timestamps); getErr != nil && !errors.Is(getErr, cache.ErrCacheMiss) {
+		return false, 0, now.Add(window), getErr
+	}
+
+	cutoff := now.Add(-window).UnixNano()
+	kept := make([]int64, 0, len(timestamps)+1)
+	for _, ts := range timestamps {
+		if ts > cutoff {
+			kept = append(kept, ts)
+		}
+	}
+	kept = append(kept, now.UnixNano())
+
+	if err := rl.cache.SetWithTTL(ctx, cacheKey, kept, window); err != nil {
+		return false, 0, now.Add(window), err
+	}
+
+	resetAt = now.Add(window)
+	if len(kept) > 0 {
+		resetAt = time.Unix(0, kept[0]).Add(window)
+	}
+
+	remaining = limit - len(kept)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return len(kept) <= limit, remaining, resetAt, nil
+}
+
+// tokenBucketCacheState is the value allowTokenBucket stores per key.
+type tokenBucketCacheState struct {
+	Tokens     float64   `json:"tokens"`
+	LastRefill time.Time `json:"last_refill"`
+}
+
+// allowTokenBucket refills at limit/Window tokens per second since the
+// state's LastRefill and decrements one token per allowed request, the
+// same refill math as RedisCache.EvalTokenBucket but expressed generically
+// over cache.Cache instead of a Lua script, so it works with any backend
+// at the cost of the same non-atomicity tradeoff as allowSlidingWindowLog.
+func (rl *CacheRateLimiter) allowTokenBucket(c *gin.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, resetAt time.Time, err error) {
+	ctx := c.Request.Context()
+	now := time.Now()
+	cacheKey := "ratelimit:bucket:" + key
+
+	var state tokenBucketCacheState
+	if getErr := rl.cache.Get(ctx, cacheKey, &state); getErr != nil {
+		if !errors.Is(getErr, cache.ErrCacheMiss) {
+			return false, 0, now.Add(window), getErr
+		}
+		state = tokenBucketCacheState{Tokens: float64(limit), LastRefill: now}
+	}
+
+	refillRate := float64(limit) / window.Seconds()
+	elapsed := now.Sub(state.LastRefill).Seconds()
+	state.Tokens += elapsed * refillRate
+	if state.Tokens > float64(limit) {
+		state.Tokens = float64(limit)
+	}
+	state.LastRefill = now
+
+	allowed = state.Tokens >= 1
+	if allowed {
+		state.Tokens--
+	}
+
+	if err := rl.cache.SetWithTTL(ctx, cacheKey, state, window); err != nil {
+		return false, 0, now.Add(window), err
+	}
+
+	remaining = int(state.Tokens)
+	resetAt = now.Add(window)
+
+	return allowed, remaining, resetAt, nil
+}
@@ -1,106 +1,199 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/cache"
 	"go.uber.org/zap"
 )
 
+// rateLimiterBackend does the actual token-bucket accounting for a client
+// key. RateLimiter delegates to one of these rather than keeping bucket
+// state itself, so the same middleware works whether buckets live in this
+// process (memoryBackend) or in a shared store multiple replicas can see
+// (redisBackend).
+type rateLimiterBackend interface {
+	// allow checks and decrements the bucket for key, returning whether the
+	// request is allowed and, if not, how long to wait before retrying.
+	allow(ctx context.Context, key string, rps, burst int) (allowed bool, retryAfter time.Duration, err error)
+
+	stats(key string) (tokens int, lastUpdate time.Time, exists bool)
+
+	globalStats() map[string]interface{}
+
+	shutdown()
+}
+
 type RateLimiter struct {
-	clients    map[string]*ClientBucket
+	backend rateLimiterBackend
+	logger  *zap.Logger
+
 	mutex      sync.RWMutex
-	cleanup    *time.Ticker
-	logger     *zap.Logger
 	defaultRPS int
 	burst      int
 }
 
-type ClientBucket struct {
-	tokens     int
-	lastUpdate time.Time
-	mutex      sync.Mutex
-}
-
+// NewRateLimiter builds a RateLimiter backed by per-process in-memory
+// buckets. Suitable for a single replica; behind a load balancer with
+// multiple replicas each one gets its own allowance, so prefer
+// NewRedisRateLimiter there.
 func NewRateLimiter(defaultRPS, burst int, logger *zap.Logger) *RateLimiter {
-	rl := &RateLimiter{
-		clients:    make(map[string]*ClientBucket),
+	return &RateLimiter{
+		backend:    newMemoryBackend(),
+		logger:     logger,
 		defaultRPS: defaultRPS,
 		burst:      burst,
-		logger:     logger,
+	}
+}
+
+// NewRedisRateLimiter builds a RateLimiter whose bucket state lives in
+// redisCache, so multiple server replicas behind a load balancer share one
+// allowance per client instead of multiplying it by replica count. It
+// falls back to the in-memory backend if redisCache is nil or reports
+// itself disconnected, so a down Redis doesn't take rate limiting down
+// with it.
+func NewRedisRateLimiter(redisCache *cache.RedisCache, defaultRPS, burst int, logger *zap.Logger) *RateLimiter {
+	if redisCache == nil {
+		logger.Warn("No Redis cache available, falling back to in-memory rate limiter")
+		return NewRateLimiter(defaultRPS, burst, logger)
 	}
 
-	rl.cleanup = time.NewTicker(5 * time.Minute)
-	go rl.cleanupExpiredClients()
+	if stats, err := redisCache.GetStats(context.Background()); err != nil || !stats.Connected {
+		logger.Warn("Redis unavailable, falling back to in-memory rate limiter", zap.Error(err))
+		return NewRateLimiter(defaultRPS, burst, logger)
+	}
 
-	return rl
+	return &RateLimiter{
+		backend:    newRedisBackend(redisCache),
+		logger:     logger,
+		defaultRPS: defaultRPS,
+		burst:      burst,
+	}
 }
 
 func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-
-		if !rl.allowRequest(clientIP) {
-			rl.logger.Warn("Rate limit exceeded",
-				zap.String("client_ip", clientIP),
-				zap.String("path", c.Request.URL.Path))
-
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": 60, 
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
+		rps, burst := rl.limits()
+		rl.enforce(c, rps, burst)
 	}
 }
 
+// limits returns the currently effective default RPS/burst, taken under
+// mutex so a concurrent UpdateLimits can't be observed half-applied.
+func (rl *RateLimiter) limits() (int, int) {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+	return rl.defaultRPS, rl.burst
+}
+
+// UpdateLimits hot-reloads the default RPS/burst, called by the
+// config.Manager watcher loop whenever SecurityConfig.RateLimit* changes.
+// Per-route overrides passed to RateLimitWithConfig are unaffected.
+func (rl *RateLimiter) UpdateLimits(rps, burst int) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.defaultRPS = rps
+	rl.burst = burst
+}
+
 func (rl *RateLimiter) RateLimitWithConfig(rps int, burst int) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-
-		if !rl.allowRequestWithConfig(clientIP, rps, burst) {
-			rl.logger.Warn("Rate limit exceeded with custom config",
-				zap.String("client_ip", clientIP),
-				zap.String("path", c.Request.URL.Path),
-				zap.Int("rps", rps))
-
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": 60,
-			})
-			c.Abort()
-			return
-		}
+		rl.enforce(c, rps, burst)
+	}
+}
+
+func (rl *RateLimiter) enforce(c *gin.Context, rps, burst int) {
+	clientIP := c.ClientIP()
 
+	allowed, retryAfter, err := rl.backend.allow(c.Request.Context(), clientIP, rps, burst)
+	if err != nil {
+		rl.logger.Warn("Rate limiter backend error, allowing request", zap.Error(err))
 		c.Next()
+		return
+	}
+
+	if !allowed {
+		rl.logger.Warn("Rate limit exceeded",
+			zap.String("client_ip", clientIP),
+			zap.String("path", c.Request.URL.Path))
+
+		retryAfterSeconds := int(retryAfter.Round(time.Second).Seconds())
+		if retryAfterSeconds < 1 {
+			retryAfterSeconds = 1
+		}
+
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Rate limit exceeded",
+			"retry_after": retryAfterSeconds,
+		})
+		c.Abort()
+		return
 	}
+
+	c.Next()
+}
+
+func (rl *RateLimiter) GetClientStats(clientIP string) (tokens int, lastUpdate time.Time, exists bool) {
+	return rl.backend.stats(clientIP)
 }
 
-func (rl *RateLimiter) allowRequest(clientIP string) bool {
-	return rl.allowRequestWithConfig(clientIP, rl.defaultRPS, rl.burst)
+func (rl *RateLimiter) GetGlobalStats() map[string]interface{} {
+	rps, burst := rl.limits()
+	stats := rl.backend.globalStats()
+	stats["default_rps"] = rps
+	stats["burst_capacity"] = burst
+	return stats
 }
 
-func (rl *RateLimiter) allowRequestWithConfig(clientIP string, rps, burst int) bool {
-	rl.mutex.Lock()
-	bucket, exists := rl.clients[clientIP]
+func (rl *RateLimiter) Shutdown() {
+	rl.backend.shutdown()
+}
+
+// memoryBackend is the original per-process token-bucket implementation,
+// now behind rateLimiterBackend.
+type memoryBackend struct {
+	clients map[string]*clientBucket
+	mutex   sync.RWMutex
+	cleanup *time.Ticker
+}
+
+type clientBucket struct {
+	tokens     int
+	lastUpdate time.Time
+	mutex      sync.Mutex
+}
+
+func newMemoryBackend() *memoryBackend {
+	b := &memoryBackend{
+		clients: make(map[string]*clientBucket),
+		cleanup: time.NewTicker(5 * time.Minute),
+	}
+	go b.cleanupExpiredClients()
+	return b
+}
+
+func (b *memoryBackend) allow(ctx context.Context, key string, rps, burst int) (bool, time.Duration, error) {
+	b.mutex.Lock()
+	bucket, exists := b.clients[key]
 	if !exists {
-		bucket = &ClientBucket{
+		bucket = &clientBucket{
 			tokens:     burst,
 			lastUpdate: time.Now(),
 		}
-		rl.clients[clientIP] = bucket
+		b.clients[key] = bucket
 	}
-	rl.mutex.Unlock()
+	b.mutex.Unlock()
 
-	return bucket.allowRequest(rps, burst)
+	return bucket.allow(rps, burst)
 }
 
-func (cb *ClientBucket) allowRequest(rps, burst int) bool {
+func (cb *clientBucket) allow(rps, burst int) (bool, time.Duration, error) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
@@ -118,54 +211,93 @@ func (cb *ClientBucket) allowRequest(rps, burst int) bool {
 
 	if cb.tokens > 0 {
 		cb.tokens--
-		return true
+		return true, 0, nil
+	}
+
+	retryAfter := time.Duration(float64(time.Second) / float64(rps))
+	return false, retryAfter, nil
+}
+
+func (b *memoryBackend) stats(key string) (int, time.Time, bool) {
+	b.mutex.RLock()
+	bucket, exists := b.clients[key]
+	b.mutex.RUnlock()
+
+	if !exists {
+		return 0, time.Time{}, false
+	}
+
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+	return bucket.tokens, bucket.lastUpdate, true
+}
+
+func (b *memoryBackend) globalStats() map[string]interface{} {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return map[string]interface{}{
+		"backend":        "memory",
+		"active_clients": len(b.clients),
 	}
+}
 
-	return false
+func (b *memoryBackend) shutdown() {
+	if b.cleanup != nil {
+		b.cleanup.Stop()
+	}
 }
 
-func (rl *RateLimiter) cleanupExpiredClients() {
-	for range rl.cleanup.C {
-		rl.mutex.Lock()
+func (b *memoryBackend) cleanupExpiredClients() {
+	for range b.cleanup.C {
+		b.mutex.Lock()
 		now := time.Now()
-		for ip, bucket := range rl.clients {
+		for ip, bucket := range b.clients {
 			bucket.mutex.Lock()
 			if now.Sub(bucket.lastUpdate) > 10*time.Minute {
-				delete(rl.clients, ip)
+				delete(b.clients, ip)
 			}
 			bucket.mutex.Unlock()
 		}
-		rl.mutex.Unlock()
+		b.mutex.Unlock()
 	}
 }
 
-func (rl *RateLimiter) GetClientStats(clientIP string) (tokens int, lastUpdate time.Time, exists bool) {
-	rl.mutex.RLock()
-	bucket, exists := rl.clients[clientIP]
-	rl.mutex.RUnlock()
+// redisBackend runs the token-bucket check through cache.RedisCache's
+// EvalTokenBucket so multiple server replicas share one bucket per client
+// key instead of each replica keeping its own.
+type redisBackend struct {
+	cache *cache.RedisCache
+}
 
-	if !exists {
-		return 0, time.Time{}, false
+func newRedisBackend(redisCache *cache.RedisCache) *redisBackend {
+	return &redisBackend{cache: redisCache}
+}
+
+func (b *redisBackend) allow(ctx context.Context, key string, rps, burst int) (bool, time.Duration, error) {
+	_, retryAfterMs, err := b.cache.EvalTokenBucket(ctx, "ratelimit:"+key, rps, burst)
+	if err != nil {
+		return false, 0, err
 	}
 
-	bucket.mutex.Lock()
-	defer bucket.mutex.Unlock()
-	return bucket.tokens, bucket.lastUpdate, true
+	if retryAfterMs > 0 {
+		return false, time.Duration(retryAfterMs) * time.Millisecond, nil
+	}
+
+	return true, 0, nil
 }
 
-func (rl *RateLimiter) GetGlobalStats() map[string]interface{} {
-	rl.mutex.RLock()
-	defer rl.mutex.RUnlock()
+func (b *redisBackend) stats(key string) (int, time.Time, bool) {
+	// EvalTokenBucket folds read+decrement into one script call and this
+	// backend has no read-only inspection of a bucket, so per-key stats
+	// always report exists=false for the Redis backend.
+	return 0, time.Time{}, false
+}
 
+func (b *redisBackend) globalStats() map[string]interface{} {
 	return map[string]interface{}{
-		"active_clients": len(rl.clients),
-		"default_rps":    rl.defaultRPS,
-		"burst_capacity": rl.burst,
+		"backend": "redis",
 	}
 }
 
-func (rl *RateLimiter) Shutdown() {
-	if rl.cleanup != nil {
-		rl.cleanup.Stop()
-	}
-}
+func (b *redisBackend) shutdown() {}
@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// reproducerMaxFileSize bounds each rotated capture file so a burst of
+// failing requests doesn't grow a single file without limit.
+const reproducerMaxFileSize = 64 * 1024 * 1024
+
+// sensitiveHeaders lists header names redactHeaders blanks out before a
+// Capture is written to disk - credentials and session tokens have no
+// business sitting in plaintext NDJSON under the upload dir, and
+// ReplayHandler replaying them against the live router would be a
+// credential-replay vector on top of that. Redacted headers mean replay
+// can't reuse the original caller's live auth; a capture that needs
+// re-authenticating has to be replayed with fresh credentials.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Api-Key":            true,
+	"X-Amz-Security-Token": true,
+}
+
+// redactHeaders returns a copy of headers with every sensitive header
+// value replaced by a fixed placeholder, preserving the header's presence
+// (and multiplicity) so a capture still shows what was sent without
+// storing the secret itself.
+func redactHeaders(headers http.Header) map[string][]string {
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		if sensitiveHeaders[key] {
+			redacted[key] = make([]string, len(values))
+			for i := range values {
+				redacted[key][i] = "[REDACTED]"
+			}
+			continue
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// Capture is one recorded request or crashing ML frame, written as a line
+// of NDJSON so captures can be tailed, grepped, or shipped to an object
+// store without a database.
+type Capture struct {
+	ID                 string              `json:"id"`
+	Timestamp          time.Time           `json:"timestamp"`
+	Method             string              `json:"method"`
+	Path               string              `json:"path"`
+	Query              string              `json:"query,omitempty"`
+	Headers            map[string][]string `json:"headers,omitempty"`
+	BodyBase64         string              `json:"body_base64,omitempty"`
+	ClientIP           string              `json:"client_ip,omitempty"`
+	StatusCode         int                 `json:"status_code"`
+	ResponseBodyBase64 string              `json:"response_body_base64,omitempty"`
+	Panic              string              `json:"panic,omitempty"`
+	Hash               string              `json:"hash"`
+}
+
+// bodyCapturingWriter wraps gin.ResponseWriter so Middleware can inspect
+// the response body after the handler chain finishes, without changing
+// what's written to the real client connection.
+type bodyCapturingWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bodyCapturingWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Reproducer captures full request context for any request that ends in
+// a 5xx response, including panics gin.Recovery recovers into one, and for
+// frames that crash an ML worker (see RecordCrash). Captures coalesce by
+// hash of method+path+body so a repeating corner case doesn't fill the
+// capture directory with duplicates.
+type Reproducer struct {
+	dir    string
+	logger *zap.Logger
+
+	mutex    sync.Mutex
+	file     *os.File
+	fileSize int64
+	seenHash map[string]bool
+}
+
+func NewReproducer(dir string, logger *zap.Logger) (*Reproducer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create reproducer dir: %w", err)
+	}
+
+	r := &Reproducer{
+		dir:      dir,
+		logger:   logger,
+		seenHash: make(map[string]bool),
+	}
+
+	if err := r.openCurrentFile(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Reproducer) openCurrentFile() error {
+	path := filepath.Join(r.dir, fmt.Sprintf("captures-%d.ndjson", time.Now().UnixNano()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.fileSize = 0
+	return nil
+}
+
+// Middleware records any request whose handler chain finishes with a 5xx
+// status, whether from a normal error response or a panic a downstream
+// gin.Recovery already turned into one. Register it before gin.Recovery()
+// in the chain so it observes the final status after recovery has run.
+func (r *Reproducer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var bodyBytes []byte
+		if c.Request.Body != nil {
+			bodyBytes, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+
+		capturingWriter := &bodyCapturingWriter{ResponseWriter: c.Writer}
+		c.Writer = capturingWriter
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			r.captureRequest(c, bodyBytes, capturingWriter.buf.Bytes())
+		}
+	}
+}
+
+func (r *Reproducer) captureRequest(c *gin.Context, body, responseBody []byte) {
+	hash := hashCapture(c.Request.Method+c.Request.URL.Path, body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.seenHash[hash] {
+		return
+	}
+	r.seenHash[hash] = true
+
+	r.writeLocked(Capture{
+		ID:                 hash[:16],
+		Timestamp:          time.Now(),
+		Method:             c.Request.Method,
+		Path:               c.Request.URL.Path,
+		Query:              c.Request.URL.RawQuery,
+		Headers:            redactHeaders(c.Request.Header),
+		BodyBase64:         base64.StdEncoding.EncodeToString(body),
+		ClientIP:           c.ClientIP(),
+		StatusCode:         c.Writer.Status(),
+		ResponseBodyBase64: base64.StdEncoding.EncodeToString(responseBody),
+		Hash:               hash,
+	})
+}
+
+// RecordCrash implements processor.CrashRecorder: it captures a frame that
+// crashed an ML worker the same way an HTTP 5xx is captured, tagging it
+// with source (e.g. "frame_processor.processFrame") instead of a request
+// path, so operators can replay the exact frame that broke a worker
+// without asking a user to resend a whole clip.
+func (r *Reproducer) RecordCrash(source string, frameData []byte, recovered interface{}) {
+	hash := hashCapture(source, frameData)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.seenHash[hash] {
+		return
+	}
+	r.seenHash[hash] = true
+
+	r.writeLocked(Capture{
+		ID:         hash[:16],
+		Timestamp:  time.Now(),
+		Method:     "WORKER",
+		Path:       source,
+		BodyBase64: base64.StdEncoding.EncodeToString(frameData),
+		Panic:      fmt.Sprintf("%v", recovered),
+		Hash:       hash,
+	})
+}
+
+func (r *Reproducer) writeLocked(capture Capture) {
+	line, err := json.Marshal(capture)
+	if err != nil {
+		r.logger.Warn("Failed to marshal capture", zap.Error(err))
+		return
+	}
+	line = append(line, '\n')
+
+	if r.fileSize+int64(len(line)) > reproducerMaxFileSize {
+		r.file.Close()
+		if err := r.openCurrentFile(); err != nil {
+			r.logger.Error("Failed to rotate reproducer capture file", zap.Error(err))
+			return
+		}
+	}
+
+	n, err := r.file.Write(line)
+	if err != nil {
+		r.logger.Error("Failed to write capture", zap.Error(err))
+		return
+	}
+	r.fileSize += int64(n)
+
+	r.logger.Info("Captured request for replay",
+		zap.String("id", capture.ID), zap.String("path", capture.Path))
+}
+
+func hashCapture(key string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Load finds a capture by ID across every rotated NDJSON file in dir.
+func (r *Reproducer) Load(id string) (*Capture, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(r.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var capture Capture
+			if err := json.Unmarshal(line, &capture); err != nil {
+				continue
+			}
+			if capture.ID == id {
+				return &capture, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("capture not found: %s", id)
+}
+
+func (r *Reproducer) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
@@ -131,11 +131,30 @@ func TimeoutHandler(timeout time.Duration) gin.HandlerFunc {
 	}
 }
 
+// allowedContentTypes lists request Content-Types InputValidation accepts
+// on POST/PUT beyond the default application/json, e.g. the raw frame
+// formats accepted by the binary frame-streaming endpoint.
+var allowedContentTypes = []string{
+	"application/json",
+	"image/jpeg",
+	"image/png",
+	"application/x-yuv4mpegstream",
+}
+
 func InputValidation() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == "POST" || c.Request.Method == "PUT" {
 			contentType := c.GetHeader("Content-Type")
-			if !strings.Contains(contentType, "application/json") {
+
+			allowed := false
+			for _, t := range allowedContentTypes {
+				if strings.Contains(contentType, t) {
+					allowed = true
+					break
+				}
+			}
+
+			if !allowed {
 				c.JSON(http.StatusBadRequest, gin.H{
 					"error": "Invalid content type",
 				})
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCanonicalQueryString(t *testing.T) {
+	values := url.Values{
+		"b": []string{"2"},
+		"a": []string{"1", " "},
+	}
+
+	got := canonicalQueryString(values)
+	want := "a=+&a=1&b=2"
+	if got != want {
+		t.Errorf("canonicalQueryString() = %q, want %q", got, want)
+	}
+}
+
+func TestDeriveSigningKey(t *testing.T) {
+	key1 := deriveSigningKey("secret", "20150830", "us-east-1", "service")
+	key2 := deriveSigningKey("secret", "20150830", "us-east-1", "service")
+	if string(key1) != string(key2) {
+		t.Error("deriveSigningKey() is not deterministic for identical inputs")
+	}
+
+	key3 := deriveSigningKey("other-secret", "20150830", "us-east-1", "service")
+	if string(key1) == string(key3) {
+		t.Error("deriveSigningKey() produced the same key for different secrets")
+	}
+}
+
+func TestParseSigV4Authorization(t *testing.T) {
+	auth := "AWS4-HMAC-SHA256 Credential=AKID/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=abcd"
+
+	parsed, err := parseSigV4Authorization(auth)
+	if err != nil {
+		t.Fatalf("parseSigV4Authorization() error = %v", err)
+	}
+	if parsed.accessKey != "AKID" {
+		t.Errorf("accessKey = %q, want AKID", parsed.accessKey)
+	}
+	if parsed.date != "20150830" || parsed.region != "us-east-1" || parsed.service != "service" {
+		t.Errorf("credential scope parts = %q/%q/%q, want 20150830/us-east-1/service", parsed.date, parsed.region, parsed.service)
+	}
+	if parsed.credentialScope != "20150830/us-east-1/service/aws4_request" {
+		t.Errorf("credentialScope = %q", parsed.credentialScope)
+	}
+	if len(parsed.signedHeaders) != 2 || parsed.signedHeaders[0] != "host" || parsed.signedHeaders[1] != "x-amz-date" {
+		t.Errorf("signedHeaders = %v", parsed.signedHeaders)
+	}
+	if parsed.signature != "abcd" {
+		t.Errorf("signature = %q, want abcd", parsed.signature)
+	}
+}
+
+func TestParseSigV4AuthorizationRejectsWrongScheme(t *testing.T) {
+	if _, err := parseSigV4Authorization("Bearer sometoken"); err == nil {
+		t.Error("expected error for non-SigV4 scheme, got nil")
+	}
+}
+
+func TestParseSigV4AuthorizationRejectsMalformedCredential(t *testing.T) {
+	auth := "AWS4-HMAC-SHA256 Credential=AKID/20150830, SignedHeaders=host, Signature=abcd"
+	if _, err := parseSigV4Authorization(auth); err == nil {
+		t.Error("expected error for malformed credential scope, got nil")
+	}
+}
+
+func TestBuildCanonicalRequestUsesHostHeaderFallback(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/frames", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("x-amz-date", "20150830T123600Z")
+
+	canonical, err := buildCanonicalRequest(req, []string{"host", "x-amz-date"}, "payloadhash")
+	if err != nil {
+		t.Fatalf("buildCanonicalRequest() error = %v", err)
+	}
+	if canonical == "" {
+		t.Error("buildCanonicalRequest() returned empty string")
+	}
+}
+
+func TestBuildCanonicalRequestErrorsOnMissingSignedHeader(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/frames", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	if _, err := buildCanonicalRequest(req, []string{"x-missing-header"}, "payloadhash"); err == nil {
+		t.Error("expected error for missing signed header, got nil")
+	}
+}
+
+func TestVerifyRejectsStaleAmzDate(t *testing.T) {
+	s := NewSigV4Auth(map[string]string{"AKID": "secret"}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/frames", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	staleDate := time.Now().Add(-(sigV4MaxSkew + time.Minute)).UTC().Format("20060102T150405Z")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20150830/us-east-1/service/aws4_request, SignedHeaders=host, Signature=abcd")
+	req.Header.Set("x-amz-date", staleDate)
+	req.Header.Set("x-amz-content-sha256", "payloadhash")
+
+	if err := s.verify(req); err == nil {
+		t.Error("verify() accepted a request with x-amz-date far outside the allowed skew window")
+	}
+}
+
+func TestVerifyRejectsFutureDatedAmzDate(t *testing.T) {
+	s := NewSigV4Auth(map[string]string{"AKID": "secret"}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/frames", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	futureDate := time.Now().Add(sigV4MaxSkew + time.Minute).UTC().Format("20060102T150405Z")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20150830/us-east-1/service/aws4_request, SignedHeaders=host, Signature=abcd")
+	req.Header.Set("x-amz-date", futureDate)
+	req.Header.Set("x-amz-content-sha256", "payloadhash")
+
+	if err := s.verify(req); err == nil {
+		t.Error("verify() accepted a future-dated x-amz-date")
+	}
+}
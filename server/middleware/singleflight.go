@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/san-kum/motorcycle-cv/server/cache"
+)
+
+// singleflightResult is the captured response of the lock holder's call to
+// c.Next(), stored in cacheInstance so every waiter that was blocked behind
+// the lock can be answered with it directly instead of re-running the
+// handler itself.
+type singleflightResult struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// singleflightWriter buffers the response body alongside writing it to the
+// real client connection, the same pattern Reproducer's bodyCapturingWriter
+// uses, so the lock holder's result can be captured after c.Next() returns.
+type singleflightWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *singleflightWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Singleflight returns middleware that runs the work identified by keyFunc
+// at most once across replicas at a time: the first request to arrive
+// acquires a Cache.Lock and actually runs the handler, capturing its
+// response into cacheInstance once it completes. Every other concurrent
+// request for the same key blocks behind the lock, then reads that cached
+// response instead of running the handler itself - true call coalescing,
+// not just mutual exclusion. Useful in front of expensive handlers like VIN
+// decoding or report generation where duplicate concurrent work is wasteful.
+//
+// A lock backend error fails open (the request proceeds unlocked), the
+// same tradeoff CacheRateLimiter.Limit makes - a lock being unreachable
+// shouldn't take the whole API down with it.
+func Singleflight(cacheInstance cache.Cache, keyFunc func(c *gin.Context) string, ttl time.Duration) gin.HandlerFunc {
+	const retryInterval = 50 * time.Millisecond
+
+	return func(c *gin.Context) {
+		key := "singleflight:" + keyFunc(c)
+		resultKey := key + ":result"
+		ctx := c.Request.Context()
+		deadline := time.Now().Add(ttl)
+
+		waited := false
+		for {
+			// A waiter looks for the holder's result before retrying Lock:
+			// the holder stores it before releasing the lock, so a waiter
+			// that took the freed lock first would run the handler again.
+			if waited {
+				var result singleflightResult
+				if getErr := cacheInstance.Get(ctx, resultKey, &result); getErr == nil {
+					c.Data(result.StatusCode, result.ContentType, result.Body)
+					c.Abort()
+					return
+				}
+			}
+
+			lock, err := cacheInstance.Lock(ctx, key, ttl)
+			if err == nil {
+				defer lock.Unlock(ctx)
+
+				capturingWriter := &singleflightWriter{ResponseWriter: c.Writer}
+				c.Writer = capturingWriter
+				c.Next()
+
+				result := singleflightResult{
+					StatusCode:  capturingWriter.Status(),
+					ContentType: capturingWriter.Header().Get("Content-Type"),
+					Body:        capturingWriter.buf.Bytes(),
+				}
+				// Best-effort: if this fails, waiters simply won't find a
+				// cached result, and the next one to take the released lock
+				// runs the handler itself - no worse than Singleflight being
+				// unavailable entirely.
+				_ = cacheInstance.SetWithTTL(ctx, resultKey, result, ttl)
+				return
+			}
+
+			if !errors.Is(err, cache.ErrLockHeld) {
+				c.Next()
+				return
+			}
+			waited = true
+
+			if time.Now().After(deadline) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Timed out waiting for an in-progress request"})
+				c.Abort()
+				return
+			}
+
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+				c.Abort()
+				return
+			}
+		}
+	}
+}
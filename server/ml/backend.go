@@ -0,0 +1,24 @@
+package ml
+
+import (
+	"context"
+
+	"github.com/san-kum/motorcycle-cv/server/models"
+)
+
+// Backend is the interface FrameProcessor depends on, so the WebSocket
+// handler and processor package stay unaware of which ML backend is
+// actually serving inference. Client (plain HTTP) implements it, alongside
+// GRPCBackend (a persistent bidi stream, for lower per-frame overhead) and
+// ONNXBackend (in-process inference for edge deployments with no ML
+// service to call out to).
+type Backend interface {
+	// AnalyzeFrame returns early with ctx.Err() if ctx is cancelled before a
+	// result is available, so an abandoned WebSocket connection or a
+	// request whose context is done doesn't keep a call to the backend
+	// running to completion for nothing.
+	AnalyzeFrame(ctx context.Context, request *models.FrameRequest) (*models.AnalysisResult, error)
+	HealthCheck() error
+	UpdateConfig(config map[string]interface{}) error
+	GetModelInfo() (map[string]interface{}, error)
+}
@@ -2,21 +2,41 @@ package ml
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/san-kum/motorcycle-cv/server/codec"
 	"github.com/san-kum/motorcycle-cv/server/models"
+	"github.com/vmihailenco/msgpack/v5"
 	"go.uber.org/zap"
 )
 
+// RequestEncoding selects how AnalyzeFrame marshals the request body sent
+// upstream. JSON base64-encodes ImageData (~33% overhead); msgpack and
+// protobuf carry it as raw bytes instead.
+type RequestEncoding string
+
+const (
+	EncodingJSON     RequestEncoding = "json"
+	EncodingMsgpack  RequestEncoding = "msgpack"
+	EncodingProtobuf RequestEncoding = "protobuf"
+)
+
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	logger     *zap.Logger
-	config     *ClientConfig
+	baseURL     string
+	httpClient  *http.Client
+	logger      *zap.Logger
+	healthTimer *time.Ticker
+
+	cfgMu           sync.RWMutex
+	config          *ClientConfig
+	requestEncoding RequestEncoding
+	analyzeObserver func(AnalyzeEvent)
 }
 
 type ClientConfig struct {
@@ -26,10 +46,41 @@ type ClientConfig struct {
 	HealthCheckInterval time.Duration
 }
 
+// AnalyzeEvent describes one completed AnalyzeFrame call, reported to
+// whatever SetAnalyzeObserver registered - normally
+// processor.FrameProcessor.SetObserver, bridging it to the
+// processor.PipelineObserver it was given.
+type AnalyzeEvent struct {
+	ClientID   string
+	ImageBytes int
+	Duration   time.Duration
+	Retries    int
+	Err        error
+}
+
+// SetAnalyzeObserver registers fn to be called once per AnalyzeFrame call
+// with its outcome. This is how FrameProcessor observes ML-layer timing
+// and retry counts without this package importing processor - fn is a
+// plain callback rather than a processor.PipelineObserver value.
+func (c *Client) SetAnalyzeObserver(fn func(AnalyzeEvent)) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.analyzeObserver = fn
+}
+
+func (c *Client) reportAnalyze(event AnalyzeEvent) {
+	c.cfgMu.RLock()
+	fn := c.analyzeObserver
+	c.cfgMu.RUnlock()
+	if fn != nil {
+		fn(event)
+	}
+}
+
 type AnalysisRequest struct {
-	ImageData []byte                 `json:"image_data"`
-	Timestamp int64                  `json:"timestamp"`
-	Config    map[string]interface{} `json:"config,omitempty"`
+	ImageData []byte                 `json:"image_data" msgpack:"image_data"`
+	Timestamp int64                  `json:"timestamp" msgpack:"timestamp"`
+	Config    map[string]interface{} `json:"config,omitempty" msgpack:"config,omitempty"`
 }
 
 type AnalysisResponse struct {
@@ -86,9 +137,11 @@ func NewClient(baseURL string, logger *zap.Logger) (*Client, error) {
 	}
 
 	client := &Client{
-		baseURL: baseURL,
-		logger:  logger,
-		config:  config,
+		baseURL:         baseURL,
+		logger:          logger,
+		config:          config,
+		requestEncoding: EncodingJSON,
+		healthTimer:     time.NewTicker(config.HealthCheckInterval),
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 			Transport: &http.Transport{
@@ -108,7 +161,64 @@ func NewClient(baseURL string, logger *zap.Logger) (*Client, error) {
 	return client, nil
 }
 
-func (c *Client) AnalyzeFrame(request *models.FrameRequest) (*models.AnalysisResult, error) {
+// SetRequestEncoding changes how subsequent AnalyzeFrame calls marshal the
+// request body upstream. Pass EncodingMsgpack or EncodingProtobuf only if
+// the ML service advertises support for application/msgpack or
+// application/x-protobuf; it otherwise falls back to JSON.
+func (c *Client) SetRequestEncoding(encoding RequestEncoding) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	c.requestEncoding = encoding
+}
+
+func (c *Client) encoding() RequestEncoding {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	return c.requestEncoding
+}
+
+// clientConfig returns a snapshot of the current retry/timeout settings,
+// taken under cfgMu so a concurrent ApplyConfig can't be observed half
+// applied.
+func (c *Client) clientConfig() ClientConfig {
+	c.cfgMu.RLock()
+	defer c.cfgMu.RUnlock()
+	return *c.config
+}
+
+// ApplyConfig hot-reloads the client's retry/timeout settings and request
+// encoding from cfg, called by the config.Manager watcher loop whenever
+// MLConfig changes. baseURL and Backend aren't included - switching ML
+// backend entirely (http/grpc/onnx) means constructing a different
+// ml.Backend implementation, which newMLBackend in main.go already does
+// once at startup; that wiring isn't itself hot-swappable.
+func (c *Client) ApplyConfig(cfg ClientConfig, encoding RequestEncoding) {
+	c.cfgMu.Lock()
+	c.config = &cfg
+	c.requestEncoding = encoding
+	c.cfgMu.Unlock()
+
+	c.httpClient.Timeout = cfg.Timeout
+	c.healthTimer.Reset(cfg.HealthCheckInterval)
+}
+
+func (c *Client) AnalyzeFrame(ctx context.Context, request *models.FrameRequest) (result *models.AnalysisResult, err error) {
+	start := time.Now()
+	attempts := 0
+	defer func() {
+		c.reportAnalyze(AnalyzeEvent{
+			ClientID:   request.ClientID,
+			ImageBytes: len(request.ImageData),
+			Duration:   time.Since(start),
+			Retries:    attempts,
+			Err:        err,
+		})
+	}()
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	mlRequest := &AnalysisRequest{
 		ImageData: request.ImageData,
 		Timestamp: request.Timestamp,
@@ -117,39 +227,53 @@ func (c *Client) AnalyzeFrame(request *models.FrameRequest) (*models.AnalysisRes
 		},
 	}
 
+	cfg := c.clientConfig()
+
 	var lastErr error
-	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		attempts = attempt
 		if attempt > 0 {
 			c.logger.Warn("Retrying ML analysis request",
 				zap.Int("attempt", attempt),
 				zap.Error(lastErr))
-			time.Sleep(c.config.RetryDelay * time.Duration(attempt))
+			select {
+			case <-time.After(cfg.RetryDelay * time.Duration(attempt)):
+			case <-ctx.Done():
+				err = ctx.Err()
+				return nil, err
+			}
 		}
 
-		result, err := c.executeAnalysisRequest(mlRequest)
+		var attemptResult *models.AnalysisResult
+		attemptResult, err = c.executeAnalysisRequest(ctx, mlRequest)
 		if err == nil {
-			return result, nil
+			return attemptResult, nil
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return nil, err
 		}
 		lastErr = err
 	}
 
-	return nil, fmt.Errorf("ML analysis failed after %d attempts: %w",
-		c.config.MaxRetries, lastErr)
+	err = fmt.Errorf("ML analysis failed after %d attempts: %w", cfg.MaxRetries+1, lastErr)
+	return nil, err
 }
 
-func (c *Client) executeAnalysisRequest(request *AnalysisRequest) (*models.AnalysisResult, error) {
-	requestData, err := json.Marshal(request)
+func (c *Client) executeAnalysisRequest(ctx context.Context, request *AnalysisRequest) (*models.AnalysisResult, error) {
+	requestData, contentType, err := c.marshalAnalysisRequest(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/analyze", c.baseURL)
-	httpRequest, err := http.NewRequest("POST", url, bytes.NewBuffer(requestData))
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Content-Type", contentType)
+	httpRequest.Header.Set("Accept", "application/json")
 	httpRequest.Header.Set("User-Agent", "motorcycle-feedback-system/1.0")
 
 	response, err := c.httpClient.Do(httpRequest)
@@ -172,7 +296,54 @@ func (c *Client) executeAnalysisRequest(request *AnalysisRequest) (*models.Analy
 	return c.convertMLResponse(&mlResponse), nil
 }
 
+// marshalAnalysisRequest encodes request per c.requestEncoding and returns
+// the bytes alongside the Content-Type to send with them. The response is
+// always expected back as JSON (see executeAnalysisRequest's Accept
+// header) - only the request body's encoding is negotiated, since that's
+// the side carrying the base64-inflated image bytes.
+func (c *Client) marshalAnalysisRequest(request *AnalysisRequest) ([]byte, string, error) {
+	switch c.encoding() {
+	case EncodingMsgpack:
+		data, err := msgpack.Marshal(request)
+		return data, "application/msgpack", err
+	case EncodingProtobuf:
+		return marshalAnalysisRequestProtobuf(request), "application/x-protobuf", nil
+	default:
+		data, err := json.Marshal(request)
+		return data, "application/json", err
+	}
+}
+
+// Field numbers for the hand-rolled protobuf encoding of AnalysisRequest -
+// see codec.ReadFields for the wire format this writes. Config is an
+// arbitrary map, which protobuf can't represent without a schema, so it
+// rides along as a JSON-encoded bytes field rather than being dropped.
+const (
+	analysisFieldImageData = 1
+	analysisFieldTimestamp = 2
+	analysisFieldConfig    = 3
+)
+
+func marshalAnalysisRequestProtobuf(request *AnalysisRequest) []byte {
+	var buf []byte
+	buf = codec.AppendBytesField(buf, analysisFieldImageData, request.ImageData)
+	buf = codec.AppendVarintField(buf, analysisFieldTimestamp, uint64(request.Timestamp))
+	if len(request.Config) > 0 {
+		if configJSON, err := json.Marshal(request.Config); err == nil {
+			buf = codec.AppendBytesField(buf, analysisFieldConfig, configJSON)
+		}
+	}
+	return buf
+}
+
 func (c *Client) convertMLResponse(mlResp *AnalysisResponse) *models.AnalysisResult {
+	return convertMLResponse(mlResp)
+}
+
+// convertMLResponse is a free function (rather than only a *Client method)
+// so GRPCBackend can reuse it for responses that arrive over the gRPC
+// stream instead of an HTTP response body.
+func convertMLResponse(mlResp *AnalysisResponse) *models.AnalysisResult {
 	result := &models.AnalysisResult{
 		OverallScore:   mlResp.OverallScore,
 		PostureScore:   mlResp.PostureScore,
@@ -242,11 +413,11 @@ func (c *Client) HealthCheck() error {
 	return nil
 }
 
+// startHealthChecker runs on c.healthTimer rather than a ticker created
+// locally, so ApplyConfig can retune the interval with Reset instead of
+// needing to tear down and restart this goroutine.
 func (c *Client) startHealthChecker() {
-	ticker := time.NewTicker(c.config.HealthCheckInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
+	for range c.healthTimer.C {
 		if err := c.HealthCheck(); err != nil {
 			c.logger.Error("ML service health check failed", zap.Error(err))
 		} else {
@@ -0,0 +1,186 @@
+package ml
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/san-kum/motorcycle-cv/server/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const mlStreamMethod = "/motorcycle_cv.ml.MLService/AnalyzeFrameStream"
+
+// frameStreamRequest/frameStreamResponse are the messages sent over the
+// bidi stream GRPCBackend keeps open; see jsonGRPCCodec for why these are
+// plain structs rather than protoc-generated proto.Message types.
+type frameStreamRequest struct {
+	RequestID string                 `json:"request_id"`
+	ImageData []byte                 `json:"image_data"`
+	Timestamp int64                  `json:"timestamp"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+}
+
+type frameStreamResponse struct {
+	RequestID string            `json:"request_id"`
+	Result    *AnalysisResponse `json:"result,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// GRPCBackend implements Backend over a single long-lived bidirectional
+// gRPC stream shared by every AnalyzeFrame call, so a frame's cost is one
+// message on an already-established HTTP/2 stream rather than a separate
+// HTTP request/response exchange per frame the way Client (HTTP) makes.
+// Responses can
+// arrive out of order, so each request carries an ID the receive loop uses
+// to route it back to the right caller.
+type GRPCBackend struct {
+	conn   *grpc.ClientConn
+	logger *zap.Logger
+
+	mutex     sync.Mutex
+	stream    grpc.ClientStream
+	pending   map[string]chan frameStreamResponse
+	nextReqID uint64
+}
+
+func NewGRPCBackend(address string, logger *zap.Logger) (*GRPCBackend, error) {
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(grpcWireCodecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial ML gRPC backend: %w", err)
+	}
+
+	b := &GRPCBackend{
+		conn:    conn,
+		logger:  logger,
+		pending: make(map[string]chan frameStreamResponse),
+	}
+
+	if err := b.openStream(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *GRPCBackend) openStream() error {
+	stream, err := b.conn.NewStream(context.Background(), &grpc.StreamDesc{
+		StreamName:    "AnalyzeFrameStream",
+		ServerStreams: true,
+		ClientStreams: true,
+	}, mlStreamMethod)
+	if err != nil {
+		return fmt.Errorf("failed to open ML gRPC stream: %w", err)
+	}
+
+	b.stream = stream
+	go b.receiveLoop(stream)
+	return nil
+}
+
+func (b *GRPCBackend) receiveLoop(stream grpc.ClientStream) {
+	for {
+		var resp frameStreamResponse
+		if err := stream.RecvMsg(&resp); err != nil {
+			if err != io.EOF {
+				b.logger.Error("ML gRPC stream closed", zap.Error(err))
+			}
+			return
+		}
+
+		b.mutex.Lock()
+		ch, ok := b.pending[resp.RequestID]
+		delete(b.pending, resp.RequestID)
+		b.mutex.Unlock()
+
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+func (b *GRPCBackend) AnalyzeFrame(ctx context.Context, request *models.FrameRequest) (*models.AnalysisResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	b.nextReqID++
+	reqID := fmt.Sprintf("%d", b.nextReqID)
+	respCh := make(chan frameStreamResponse, 1)
+	b.pending[reqID] = respCh
+	stream := b.stream
+	b.mutex.Unlock()
+
+	streamReq := frameStreamRequest{
+		RequestID: reqID,
+		ImageData: request.ImageData,
+		Timestamp: request.Timestamp,
+		Config:    map[string]interface{}{"client_id": request.ClientID},
+	}
+
+	if err := stream.SendMsg(&streamReq); err != nil {
+		b.mutex.Lock()
+		delete(b.pending, reqID)
+		b.mutex.Unlock()
+		return nil, fmt.Errorf("failed to send frame over ML gRPC stream: %w", err)
+	}
+
+	var resp frameStreamResponse
+	select {
+	case resp = <-respCh:
+	case <-ctx.Done():
+		b.mutex.Lock()
+		delete(b.pending, reqID)
+		b.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("ML gRPC backend error: %s", resp.Error)
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("ML gRPC backend returned no result")
+	}
+
+	return convertMLResponse(resp.Result), nil
+}
+
+func (b *GRPCBackend) HealthCheck() error {
+	var resp map[string]interface{}
+	if err := b.conn.Invoke(context.Background(), "/motorcycle_cv.ml.MLService/HealthCheck", struct{}{}, &resp); err != nil {
+		return fmt.Errorf("ML gRPC health check failed: %w", err)
+	}
+	return nil
+}
+
+func (b *GRPCBackend) UpdateConfig(config map[string]interface{}) error {
+	var resp struct{}
+	if err := b.conn.Invoke(context.Background(), "/motorcycle_cv.ml.MLService/UpdateConfig", config, &resp); err != nil {
+		return fmt.Errorf("ML gRPC config update failed: %w", err)
+	}
+	return nil
+}
+
+func (b *GRPCBackend) GetModelInfo() (map[string]interface{}, error) {
+	var resp map[string]interface{}
+	if err := b.conn.Invoke(context.Background(), "/motorcycle_cv.ml.MLService/GetModelInfo", struct{}{}, &resp); err != nil {
+		return nil, fmt.Errorf("ML gRPC model info request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// Close shuts down the underlying connection. Not part of Backend - callers
+// that need it (FrameProcessor.Shutdown) type-assert for it, the same way
+// processor.CrashRecorder wiring avoids requiring every backend to support
+// every optional capability.
+func (b *GRPCBackend) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,26 @@
+package ml
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+const grpcWireCodecName = "mlwire"
+
+func init() {
+	encoding.RegisterCodec(jsonGRPCCodec{})
+}
+
+// jsonGRPCCodec lets GRPCBackend stream plain Go structs (frameStreamRequest,
+// frameStreamResponse) over gRPC without protoc-generated proto.Message
+// types - grpc-go only requires a registered encoding.Codec matching the
+// CallContentSubtype, not a real protobuf message, for the wire format of a
+// given stream.
+type jsonGRPCCodec struct{}
+
+func (jsonGRPCCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonGRPCCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonGRPCCodec) Name() string { return grpcWireCodecName }
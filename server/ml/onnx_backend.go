@@ -0,0 +1,188 @@
+package ml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
+
+	"github.com/san-kum/motorcycle-cv/server/models"
+	ort "github.com/yalue/onnxruntime_go"
+	"go.uber.org/zap"
+)
+
+const (
+	onnxInputSize  = 224 // square input side expected by the exported model
+	onnxOutputSize = 4   // overall/posture/lane/speed scores
+	onnxInputName  = "input"
+	onnxOutputName = "output"
+)
+
+// ONNXBackend runs inference in-process via ONNX Runtime instead of calling
+// out to a Python ML service, for edge deployments (e.g. a dashcam unit)
+// where running that service isn't an option. It owns a single session and
+// its input/output tensors, so AnalyzeFrame calls are serialized by mutex -
+// onnxruntime_go sessions aren't safe for concurrent Run calls.
+type ONNXBackend struct {
+	modelPath string
+	logger    *zap.Logger
+
+	mutex   sync.Mutex
+	session *ort.AdvancedSession
+	input   *ort.Tensor[float32]
+	output  *ort.Tensor[float32]
+}
+
+func NewONNXBackend(modelPath string, logger *zap.Logger) (*ONNXBackend, error) {
+	if err := ort.InitializeEnvironment(); err != nil {
+		return nil, fmt.Errorf("failed to initialize ONNX runtime: %w", err)
+	}
+
+	inputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(1, 3, onnxInputSize, onnxInputSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate ONNX input tensor: %w", err)
+	}
+
+	outputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(1, onnxOutputSize))
+	if err != nil {
+		inputTensor.Destroy()
+		return nil, fmt.Errorf("failed to allocate ONNX output tensor: %w", err)
+	}
+
+	session, err := ort.NewAdvancedSession(modelPath,
+		[]string{onnxInputName}, []string{onnxOutputName},
+		[]ort.ArbitraryTensor{inputTensor}, []ort.ArbitraryTensor{outputTensor}, nil)
+	if err != nil {
+		inputTensor.Destroy()
+		outputTensor.Destroy()
+		return nil, fmt.Errorf("failed to load ONNX model %s: %w", modelPath, err)
+	}
+
+	return &ONNXBackend{
+		modelPath: modelPath,
+		logger:    logger,
+		session:   session,
+		input:     inputTensor,
+		output:    outputTensor,
+	}, nil
+}
+
+// AnalyzeFrame runs inference synchronously - there's no cancellable I/O to
+// hook ctx into, so it only checks ctx up front to skip work that's already
+// abandoned (e.g. a frame that sat behind others in the worker queue long
+// enough for its connection to close).
+func (b *ONNXBackend) AnalyzeFrame(ctx context.Context, request *models.FrameRequest) (*models.AnalysisResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := decodeFrameToCHW(request.ImageData, b.input.GetData(), onnxInputSize); err != nil {
+		return nil, fmt.Errorf("failed to decode frame for ONNX inference: %w", err)
+	}
+
+	if err := b.session.Run(); err != nil {
+		return nil, fmt.Errorf("ONNX inference failed: %w", err)
+	}
+
+	return onnxOutputToResult(b.output.GetData(), request.Timestamp), nil
+}
+
+func (b *ONNXBackend) HealthCheck() error {
+	if b.session == nil {
+		return fmt.Errorf("ONNX session not initialized")
+	}
+	return nil
+}
+
+// UpdateConfig is a no-op: the ONNX backend has no remote endpoint to push
+// config to, and inference-affecting settings (score thresholds etc.) are
+// applied by FrameProcessor itself before/after calling AnalyzeFrame.
+func (b *ONNXBackend) UpdateConfig(config map[string]interface{}) error {
+	return nil
+}
+
+func (b *ONNXBackend) GetModelInfo() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"backend":    "onnx",
+		"model_path": b.modelPath,
+	}, nil
+}
+
+// Close releases the session and tensors. Not part of Backend - see
+// GRPCBackend.Close for why that's fine.
+func (b *ONNXBackend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.session != nil {
+		b.session.Destroy()
+	}
+	if b.input != nil {
+		b.input.Destroy()
+	}
+	if b.output != nil {
+		b.output.Destroy()
+	}
+	return nil
+}
+
+// decodeFrameToCHW decodes a JPEG/PNG frame and nearest-neighbor resizes it
+// into dst as CHW float32 in [0, 1], the layout ONNX image models expect.
+// H.264 frames would need to be decoded to a raw image first - out of
+// scope here since the HTTP/WebSocket ingestion paths only hand this
+// backend still-image frames today.
+func decodeFrameToCHW(frameData []byte, dst []float32, size int) error {
+	img, _, err := image.Decode(bytes.NewReader(frameData))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	plane := size * size
+
+	for y := 0; y < size; y++ {
+		srcY := bounds.Min.Y + y*srcH/size
+		for x := 0; x < size; x++ {
+			srcX := bounds.Min.X + x*srcW/size
+			r, g, bl, _ := img.At(srcX, srcY).RGBA()
+			idx := y*size + x
+			dst[idx] = float32(r>>8) / 255.0
+			dst[plane+idx] = float32(g>>8) / 255.0
+			dst[2*plane+idx] = float32(bl>>8) / 255.0
+		}
+	}
+
+	return nil
+}
+
+func onnxOutputToResult(output []float32, timestamp int64) *models.AnalysisResult {
+	scoreAt := func(i int) int {
+		if i >= len(output) {
+			return 0
+		}
+		v := output[i] * 100
+		switch {
+		case v < 0:
+			return 0
+		case v > 100:
+			return 100
+		default:
+			return int(v)
+		}
+	}
+
+	return &models.AnalysisResult{
+		OverallScore: scoreAt(0),
+		PostureScore: scoreAt(1),
+		LaneScore:    scoreAt(2),
+		SpeedScore:   scoreAt(3),
+		Timestamp:    timestamp,
+	}
+}
@@ -0,0 +1,10 @@
+package processor
+
+// CrashRecorder captures a panic recovered from an ML worker along with
+// the frame bytes that triggered it, so operators can replay the exact
+// input that crashed a worker instead of asking a user to resend a whole
+// clip. It's implemented by middleware.Reproducer; kept as an interface
+// here so processor doesn't need to import middleware.
+type CrashRecorder interface {
+	RecordCrash(source string, frameData []byte, recovered interface{})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -14,7 +15,7 @@ import (
 )
 
 type FrameProcessor struct {
-	mlClient   *ml.Client
+	mlClient   ml.Backend
 	logger     *zap.Logger
 	queue      *ProcessingQueue
 	stats      *ProcessorStats
@@ -24,6 +25,11 @@ type FrameProcessor struct {
 	cache      cache.Cache
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	resourceMonitor *ResourceMonitor
+	reporter        *statsReporter
+	crashRecorder   CrashRecorder
+	observer        PipelineObserver
 }
 
 type ProcessorStats struct {
@@ -34,6 +40,21 @@ type ProcessorStats struct {
 	AverageLatency        float64   `json:"average_latency_ms"`
 	QueueSize             int       `json:"queue_size"`
 	ActiveWorkers         int       `json:"active_workers"`
+
+	// Resource usage aggregates, populated when out-of-process ML jobs
+	// are metered via ResourceMonitor. Zero when cgroup metering isn't
+	// available (e.g. non-Linux hosts).
+	PeakMemoryBytes  int64   `json:"peak_memory_bytes"`
+	TotalCPUSeconds  float64 `json:"total_cpu_seconds"`
+	CPUThrottleRatio float64 `json:"cpu_throttle_ratio"`
+
+	// Throughput/dedup aggregates surfaced by the periodic statsReporter;
+	// see reporter.go for the windowed rates and latency percentiles
+	// derived from these cumulative counters.
+	BytesProcessed int64 `json:"bytes_processed"`
+	CacheHits      int64 `json:"cache_hits"`
+	CacheMisses    int64 `json:"cache_misses"`
+	DedupSkips     int64 `json:"dedup_skips"`
 }
 
 type ProcessorConfig struct {
@@ -42,25 +63,49 @@ type ProcessorConfig struct {
 	ProcessingTimeout   int     `json:"processing_timeout_seconds"`
 	SkipSimilarFrames   bool    `json:"skip_similar_frames"`
 	SimilarityThreshold float64 `json:"similarity_threshold"`
+	VideoFPS            float64 `json:"video_fps"`
+	VideoKeyframesOnly  bool    `json:"video_keyframes_only"`
+
+	// QueueBackend selects how work items are dispatched: "channel" (the
+	// default, single-process in-memory heap) or "nats" (JetStream,
+	// letting multiple server instances share one work queue).
+	QueueBackend      string            `json:"queue_backend"`
+	NATSBackendConfig NATSBackendConfig `json:"nats_backend_config"`
 }
 
 type VideoJob struct {
-	ID        string                  `json:"id"`
-	Filename  string                  `json:"filename"`
-	Status    string                  `json:"status"`
-	Progress  float64                 `json:"progress"`
-	StartTime time.Time               `json:"start_time"`
-	Results   []models.AnalysisResult `json:"results,omitempty"`
-	Error     string                  `json:"error,omitempty"`
+	ID            string                  `json:"id"`
+	Filename      string                  `json:"filename"`
+	Status        string                  `json:"status"`
+	Progress      float64                 `json:"progress"`
+	StartTime     time.Time               `json:"start_time"`
+	TotalFrames   int                     `json:"total_frames,omitempty"`
+	Results       []models.AnalysisResult `json:"results,omitempty"`
+	Session       *models.RidingSession   `json:"session,omitempty"`
+	Error         string                  `json:"error,omitempty"`
+	ManifestReady bool                    `json:"manifest_ready"`
+
+	cancel context.CancelFunc
+
+	// mediaDir holds the segmented, overlay-annotated fMP4 output once
+	// buildMediaSegments has run, so manifest.go can serve it without
+	// re-touching the ProcessingQueue. Unlike workDir in processVideo,
+	// this directory outlives the job and is cleaned up by Shutdown.
+	mediaDir     string
+	segmentCount int
+	segmentSecs  float64
 }
 
-func NewFrameProcessor(mlClient *ml.Client, cache cache.Cache, logger *zap.Logger) *FrameProcessor {
+func NewFrameProcessor(mlClient ml.Backend, cache cache.Cache, logger *zap.Logger) *FrameProcessor {
 	config := &ProcessorConfig{
 		MaxQueueSize:        100,
 		MaxWorkers:          4,
 		ProcessingTimeout:   30,
 		SkipSimilarFrames:   true,
 		SimilarityThreshold: 0.95,
+		VideoFPS:            2.0,
+		VideoKeyframesOnly:  false,
+		QueueBackend:        "channel",
 	}
 
 	stats := &ProcessorStats{
@@ -71,24 +116,60 @@ func NewFrameProcessor(mlClient *ml.Client, cache cache.Cache, logger *zap.Logge
 	ctx, cancel := context.WithCancel(context.Background())
 
 	processor := &FrameProcessor{
-		mlClient:   mlClient,
-		logger:     logger,
-		stats:      stats,
-		config:     config,
-		jobTracker: make(map[string]*VideoJob),
-		cache:      cache,
-		ctx:        ctx,
-		cancel:     cancel,
+		mlClient:        mlClient,
+		logger:          logger,
+		stats:           stats,
+		config:          config,
+		jobTracker:      make(map[string]*VideoJob),
+		cache:           cache,
+		ctx:             ctx,
+		cancel:          cancel,
+		resourceMonitor: NewResourceMonitor(logger),
+		reporter:        newStatsReporter(logger),
 	}
 
-	processor.queue = NewProcessingQueue(config.MaxQueueSize, config.MaxWorkers, processor.processFrame)
+	processor.queue = processor.newQueue()
+
+	go processor.reporter.run(processor)
 
 	return processor
 }
 
-func (fp *FrameProcessor) ProcessFrame(request *models.FrameRequest) (*models.AnalysisResult, error) {
+// newQueue builds the ProcessingQueue on top of the configured
+// QueueBackend, falling back to the in-memory channel backend if a
+// distributed backend fails to initialize (e.g. NATS unreachable) so a
+// single down dependency doesn't prevent the server from starting.
+func (fp *FrameProcessor) newQueue() *ProcessingQueue {
+	backend := NewChannelBackend(fp.config.MaxQueueSize)
+
+	if fp.config.QueueBackend == "nats" {
+		natsBackend, err := NewNATSBackend(fp.config.NATSBackendConfig, fp.logger)
+		if err != nil {
+			fp.logger.Warn("Failed to initialize NATS queue backend, falling back to in-memory queue", zap.Error(err))
+		} else {
+			backend = natsBackend
+		}
+	}
+
+	return NewProcessingQueueWithBackend(backend, fp.config.MaxQueueSize, fp.config.MaxWorkers, fp.processFrame)
+}
+
+func (fp *FrameProcessor) ProcessFrame(ctx context.Context, request *models.FrameRequest) (result *models.AnalysisResult, err error) {
 	startTime := time.Now()
+	defer func() {
+		fp.observeFrame(FrameEvent{
+			Stage:      "process_frame",
+			ClientID:   request.ClientID,
+			ImageBytes: len(request.ImageData),
+			Timestamp:  startTime,
+			Duration:   time.Since(startTime),
+			Err:        err,
+			Result:     result,
+		})
+	}()
+
 	fp.stats.TotalProcessed++
+	fp.stats.BytesProcessed += int64(len(request.ImageData))
 
 	// Generate cache key for this frame
 	frameHash := fp.generateFrameHash(request.ImageData)
@@ -100,13 +181,16 @@ func (fp *FrameProcessor) ProcessFrame(request *models.FrameRequest) (*models.An
 		if err := fp.cache.Get(fp.ctx, cacheKey, &cachedResult); err == nil {
 			fp.logger.Debug("Cache hit for frame", zap.String("key", cacheKey))
 			fp.stats.SuccessfullyProcessed++
+			fp.stats.CacheHits++
 			return &cachedResult, nil
 		}
+		fp.stats.CacheMisses++
 	}
 
 	// Check for similar frames if enabled
-	if fp.config.SkipSimilarFrames && fp.isSimilarFrame(request.ImageData) {
+	if fp.config.SkipSimilarFrames && fp.isSimilarFrame(request.ClientID, request.ImageData) {
 		fp.logger.Debug("Skipping similar frame")
+		fp.stats.DedupSkips++
 		return fp.getCachedResult(), nil
 	}
 
@@ -117,6 +201,8 @@ func (fp *FrameProcessor) ProcessFrame(request *models.FrameRequest) (*models.An
 		ResultChan: resultChan,
 		StartTime:  startTime,
 		Priority:   fp.calculatePriority(request),
+		ClientID:   request.ClientID,
+		Ctx:        ctx,
 	}
 
 	if !fp.queue.Enqueue(queueItem) {
@@ -133,6 +219,7 @@ func (fp *FrameProcessor) ProcessFrame(request *models.FrameRequest) (*models.An
 
 		latency := time.Since(startTime)
 		fp.updateLatencyStats(latency)
+		fp.reporter.recordLatency(float64(latency.Milliseconds()))
 		fp.stats.SuccessfullyProcessed++
 
 		// Cache the result
@@ -156,16 +243,41 @@ func (fp *FrameProcessor) processFrame(item *QueueItem) {
 	defer func() {
 		if r := recover(); r != nil {
 			fp.logger.Error("Frame processing panic", zap.Any("panic", r))
+			if fp.crashRecorder != nil {
+				fp.crashRecorder.RecordCrash("frame_processor.processFrame", item.Request.ImageData, r)
+			}
 			item.ResultChan <- &ProcessingResult{
 				Error: fmt.Errorf("processing failed: %v", r),
 			}
 		}
 	}()
 
-	analysis, err := fp.mlClient.AnalyzeFrame(item.Request)
+	var jobSlice string
+	if fp.resourceMonitor.Enabled() {
+		jobID := fmt.Sprintf("%s-%d", item.ClientID, item.StartTime.UnixNano())
+		if _, err := fp.resourceMonitor.StartJob(jobID); err == nil {
+			jobSlice = jobID
+			// Nothing here writes a worker PID into "<dir>/cgroup.procs",
+			// which is why ResourceMonitor.Enabled reports false.
+		}
+	}
+	finishMetering := func() *ResourceUsage {
+		if jobSlice == "" {
+			return nil
+		}
+		usage := fp.resourceMonitor.FinishJob(jobSlice)
+		fp.updateResourceStats(usage)
+		return usage
+	}
+
+	ctx := item.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	analysis, err := fp.mlClient.AnalyzeFrame(ctx, item.Request)
 	if err != nil {
 		fp.logger.Error("ML analysis failed", zap.Error(err))
-		item.ResultChan <- &ProcessingResult{Error: err}
+		item.ResultChan <- &ProcessingResult{Error: err, ResourceUsage: finishMetering()}
 		return
 	}
 
@@ -185,7 +297,7 @@ func (fp *FrameProcessor) processFrame(item *QueueItem) {
 
 	// Cache result is handled in ProcessFrame method
 
-	item.ResultChan <- &ProcessingResult{Analysis: analysis}
+	item.ResultChan <- &ProcessingResult{Analysis: analysis, ResourceUsage: finishMetering()}
 }
 
 func (fp *FrameProcessor) generateFeedback(analysis *models.AnalysisResult) []models.Feedback {
@@ -239,19 +351,22 @@ func (fp *FrameProcessor) generateFeedback(analysis *models.AnalysisResult) []mo
 func (fp *FrameProcessor) CreateVideoJob(videoData []byte, filename, clientID string) string {
 	jobID := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s-%s-%d", filename, clientID, time.Now().UnixNano()))))
 
+	jobCtx, cancel := context.WithCancel(fp.ctx)
+
 	job := &VideoJob{
 		ID:        jobID,
 		Filename:  filename,
 		Status:    "processing",
 		Progress:  0.0,
 		StartTime: time.Now(),
+		cancel:    cancel,
 	}
 
 	fp.mutex.Lock()
 	fp.jobTracker[jobID] = job
 	fp.mutex.Unlock()
 
-	go fp.processVideo(job, videoData)
+	go fp.processVideo(jobCtx, job, videoData, clientID)
 
 	return jobID
 }
@@ -265,25 +380,26 @@ func (fp *FrameProcessor) GetJobStatus(jobID string) (*VideoJob, error) {
 		return nil, fmt.Errorf("job not found")
 	}
 
-	return job, nil
+	// Return a shallow copy so callers polling status don't race with the
+	// in-progress Results/Progress writes below.
+	snapshot := *job
+	return &snapshot, nil
 }
 
-func (fp *FrameProcessor) processVideo(job *VideoJob, videoData []byte) {
-	// TODO: Implement video frame extraction and batch processing
-	// This would involve:
-	// 1. Extract frames from video using ffmpeg or similar
-	// 2. Process each frame through the ML pipeline
-	// 3. Aggregate results and generate comprehensive feedback
-	// 4. Update job status and progress
-
-	fp.logger.Info("Video processing started", zap.String("job_id", job.ID))
-
-	time.Sleep(2 * time.Second)
-
+// CancelVideoJob cancels an in-progress video job, stopping frame
+// extraction and any outstanding ML calls.
+func (fp *FrameProcessor) CancelVideoJob(jobID string) error {
 	fp.mutex.Lock()
-	job.Status = "completed"
-	job.Progress = 100.0
-	fp.mutex.Unlock()
+	defer fp.mutex.Unlock()
+
+	job, exists := fp.jobTracker[jobID]
+	if !exists {
+		return fmt.Errorf("job not found")
+	}
+	if job.cancel != nil {
+		job.cancel()
+	}
+	return nil
 }
 
 func (fp *FrameProcessor) UpdateConfig(configMap map[string]interface{}) {
@@ -303,6 +419,15 @@ func (fp *FrameProcessor) UpdateConfig(configMap map[string]interface{}) {
 	fp.logger.Info("Configuration updated", zap.Any("config", fp.config))
 }
 
+// SetCrashRecorder wires a CrashRecorder (normally a middleware.Reproducer)
+// into the processor so a panic recovered from an ML worker captures the
+// frame that caused it. Optional: a nil recorder just skips capture.
+func (fp *FrameProcessor) SetCrashRecorder(recorder CrashRecorder) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	fp.crashRecorder = recorder
+}
+
 func (fp *FrameProcessor) GetStats() *ProcessorStats {
 	fp.mutex.RLock()
 	defer fp.mutex.RUnlock()
@@ -312,33 +437,101 @@ func (fp *FrameProcessor) GetStats() *ProcessorStats {
 	return &stats
 }
 
+// HumanReadableStats is a display-friendly view of ProcessorStats for the
+// stats endpoint: humanized byte/count units instead of raw numbers, plus
+// latency percentiles the EWMA in AverageLatency can't show.
+type HumanReadableStats struct {
+	TotalProcessed   string `json:"total_processed"`
+	BytesProcessed   string `json:"bytes_processed"`
+	CacheHitRatio    string `json:"cache_hit_ratio"`
+	DedupSkipRatio   string `json:"dedup_skip_ratio"`
+	LatencyP50       string `json:"latency_p50"`
+	LatencyP95       string `json:"latency_p95"`
+	LatencyP99       string `json:"latency_p99"`
+	PeakMemory       string `json:"peak_memory"`
+	TotalCPUSeconds  string `json:"total_cpu_seconds"`
+	CPUThrottleRatio string `json:"cpu_throttle_ratio"`
+}
+
+// GetHumanReadableStats returns ProcessorStats rendered with humanized
+// units (KB/MB/GB, k/M suffixes) for display in the stats endpoint.
+func (fp *FrameProcessor) GetHumanReadableStats() *HumanReadableStats {
+	stats := fp.GetStats()
+
+	var cacheRatio float64
+	if stats.CacheHits+stats.CacheMisses > 0 {
+		cacheRatio = float64(stats.CacheHits) / float64(stats.CacheHits+stats.CacheMisses) * 100
+	}
+
+	var dedupRatio float64
+	if stats.TotalProcessed > 0 {
+		dedupRatio = float64(stats.DedupSkips) / float64(stats.TotalProcessed) * 100
+	}
+
+	p50, p95, p99 := fp.reporter.percentiles()
+
+	return &HumanReadableStats{
+		TotalProcessed:   humanizeCount(stats.TotalProcessed),
+		BytesProcessed:   humanizeBytes(stats.BytesProcessed),
+		CacheHitRatio:    fmt.Sprintf("%.1f%%", cacheRatio),
+		DedupSkipRatio:   fmt.Sprintf("%.1f%%", dedupRatio),
+		LatencyP50:       fmt.Sprintf("%.0fms", p50),
+		LatencyP95:       fmt.Sprintf("%.0fms", p95),
+		LatencyP99:       fmt.Sprintf("%.0fms", p99),
+		PeakMemory:       humanizeBytes(stats.PeakMemoryBytes),
+		TotalCPUSeconds:  fmt.Sprintf("%.1fs", stats.TotalCPUSeconds),
+		CPUThrottleRatio: fmt.Sprintf("%.1f%%", stats.CPUThrottleRatio*100),
+	}
+}
+
 func (fp *FrameProcessor) generateFrameHash(imageData []byte) string {
 	return fmt.Sprintf("%x", md5.Sum(imageData))
 }
 
-func (fp *FrameProcessor) isSimilarFrame(imageData []byte) bool {
+const phashRingBufferSize = 16
+
+// isSimilarFrame compares imageData's perceptual hash against a small ring
+// buffer of recent hashes for this client, treating anything within
+// (1-SimilarityThreshold)*64 bits (Hamming distance) as a near-duplicate.
+// Unlike a raw MD5 comparison, this tolerates recompression noise and
+// minor pixel changes, so SimilarityThreshold actually does something.
+func (fp *FrameProcessor) isSimilarFrame(clientID string, imageData []byte) bool {
 	if !fp.config.SkipSimilarFrames {
 		return false
 	}
 
-	hash := fp.generateFrameHash(imageData)
-	similarityKey := cache.GenerateCacheKey("similarity", hash)
-
-	// Check if we've seen this hash recently
-	exists, err := fp.cache.Exists(fp.ctx, similarityKey)
+	hash, err := computePHash(imageData)
 	if err != nil {
-		fp.logger.Warn("Failed to check frame similarity", zap.Error(err))
+		fp.logger.Warn("Failed to compute perceptual hash, skipping dedup check", zap.Error(err))
 		return false
 	}
 
-	if exists {
-		return true
+	ringKey := cache.GenerateCacheKey("phash-ring", clientID)
+
+	// Decode into []uint64 directly rather than through an interface{}
+	// type assertion - a JSON-serializing Cache backend (RedisCache,
+	// TieredCache, EtcdCache) round-trips this through encoding/json as
+	// []interface{} of float64, so asserting straight to []uint64 would
+	// always fail and silently disable dedup on any non-MemoryCache
+	// backend.
+	var hashes []uint64
+	_ = fp.cache.Get(fp.ctx, ringKey, &hashes)
+
+	maxDistance := int((1 - fp.config.SimilarityThreshold) * 64)
+	for _, existing := range hashes {
+		if hammingDistance(hash, existing) <= maxDistance {
+			return true
+		}
+	}
+
+	hashes = append(hashes, hash)
+	if len(hashes) > phashRingBufferSize {
+		hashes = hashes[len(hashes)-phashRingBufferSize:]
 	}
 
-	// Mark this hash as seen
 	go func() {
-		if err := fp.cache.SetWithTTL(fp.ctx, similarityKey, true, 5*time.Minute); err != nil {
-			fp.logger.Warn("Failed to cache frame similarity", zap.Error(err))
+		if err := fp.cache.SetWithTTL(fp.ctx, ringKey, hashes, 5*time.Minute); err != nil {
+			fp.logger.Warn("Failed to cache perceptual hash ring", zap.Error(err))
 		}
 	}()
 
@@ -364,6 +557,12 @@ func (fp *FrameProcessor) calculatePriority(request *models.FrameRequest) int {
 		priority += 1
 	}
 
+	// Back off when the host is CPU-throttled, so admission control
+	// sheds load onto new requests before existing jobs get starved.
+	if fp.resourceMonitor.ThrottleRatio() > 0.5 {
+		priority -= 1
+	}
+
 	return priority
 }
 
@@ -385,6 +584,23 @@ func (fp *FrameProcessor) getCachedResult() *models.AnalysisResult {
 	}
 }
 
+func (fp *FrameProcessor) updateResourceStats(usage *ResourceUsage) {
+	if usage == nil {
+		return
+	}
+
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	if usage.PeakMemoryBytes > fp.stats.PeakMemoryBytes {
+		fp.stats.PeakMemoryBytes = usage.PeakMemoryBytes
+	}
+	fp.stats.TotalCPUSeconds += float64(usage.CPUUsageUsec) / 1e6
+
+	ratio := fp.resourceMonitor.ThrottleRatio()
+	fp.stats.CPUThrottleRatio = ratio
+}
+
 func (fp *FrameProcessor) updateLatencyStats(latency time.Duration) {
 	currentLatency := float64(latency.Milliseconds())
 
@@ -400,6 +616,18 @@ func (fp *FrameProcessor) updateLatencyStats(latency time.Duration) {
 func (fp *FrameProcessor) Shutdown() error {
 	fp.logger.Info("Shutting down frame processor...")
 
+	// Stop the periodic throughput reporter
+	fp.reporter.Stop()
+
+	// Remove any persisted DASH/HLS media left behind by completed video jobs
+	fp.mutex.RLock()
+	for _, job := range fp.jobTracker {
+		if job.mediaDir != "" {
+			os.RemoveAll(job.mediaDir)
+		}
+	}
+	fp.mutex.RUnlock()
+
 	// Cancel context
 	fp.cancel()
 
@@ -417,6 +645,15 @@ func (fp *FrameProcessor) Shutdown() error {
 		}
 	}
 
+	// Close the ML backend's connection/session if it has one to close
+	// (GRPCBackend, ONNXBackend) - Backend itself doesn't require this since
+	// Client (HTTP) has nothing persistent to release.
+	if closer, ok := fp.mlClient.(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			fp.logger.Error("Failed to close ML backend", zap.Error(err))
+		}
+	}
+
 	fp.logger.Info("Frame processor shutdown complete")
 	return nil
 }
@@ -0,0 +1,299 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/san-kum/motorcycle-cv/server/models"
+	"go.uber.org/zap"
+)
+
+// segmentDuration is the target length of each DASH/HLS media segment.
+// A few seconds keeps startup latency low while avoiding the per-segment
+// overhead of sub-second chunks.
+const segmentDuration = 4.0 // seconds
+
+// buildMediaSegments re-encodes the extracted frames for a completed video
+// job into a sequence of fragmented MP4 segments (one per segmentDuration),
+// burning in bounding-box overlays from the analysis results with ffmpeg's
+// drawbox/drawtext filters, and writes a WebVTT sidecar keyed by frame
+// timestamp for players that prefer a text track over burned-in overlays.
+// The output is written under a persistent directory (unlike the ffmpeg
+// workDir in processVideo, which is removed once extraction finishes) so
+// manifest.mpd/master.m3u8/seg-N.m4s can be served after the job completes.
+//
+// results must be index-aligned with framePaths (results[i] is the analysis
+// for framePaths[i], or nil if that frame failed analysis) - video_pipeline.go
+// builds it that way specifically so a failed frame can't shift every
+// subsequent frame's burned-in overlay and VTT cue onto the wrong frame.
+func (fp *FrameProcessor) buildMediaSegments(job *VideoJob, framePaths []string, results []*models.AnalysisResult) error {
+	if len(framePaths) == 0 {
+		return fmt.Errorf("no frames to segment")
+	}
+
+	fps := fp.config.VideoFPS
+	if fps <= 0 {
+		fps = 2.0
+	}
+	framesPerSegment := int(segmentDuration * fps)
+	if framesPerSegment < 1 {
+		framesPerSegment = 1
+	}
+
+	mediaDir := filepath.Join(os.TempDir(), "motorcycle-cv-media", job.ID)
+	if err := os.MkdirAll(mediaDir, 0755); err != nil {
+		return fmt.Errorf("failed to create media dir: %w", err)
+	}
+
+	if err := fp.writeInitSegment(mediaDir, framePaths[0], fps); err != nil {
+		return fmt.Errorf("failed to write init segment: %w", err)
+	}
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n\n")
+
+	segmentCount := 0
+	for start := 0; start < len(framePaths); start += framesPerSegment {
+		end := start + framesPerSegment
+		if end > len(framePaths) {
+			end = len(framePaths)
+		}
+
+		segName := fmt.Sprintf("seg-%d.m4s", segmentCount)
+		if err := fp.writeMediaSegment(mediaDir, segName, framePaths[start:end], results, start, fps); err != nil {
+			fp.logger.Warn("Failed to encode media segment",
+				zap.String("job_id", job.ID), zap.Int("segment", segmentCount), zap.Error(err))
+		}
+
+		writeVTTCue(&vtt, segmentCount, segmentDuration, results, start, end)
+		segmentCount++
+	}
+
+	if err := os.WriteFile(filepath.Join(mediaDir, "overlays.vtt"), []byte(vtt.String()), 0644); err != nil {
+		fp.logger.Warn("Failed to write WebVTT sidecar", zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	fp.mutex.Lock()
+	job.mediaDir = mediaDir
+	job.segmentCount = segmentCount
+	job.segmentSecs = segmentDuration
+	job.ManifestReady = segmentCount > 0
+	fp.mutex.Unlock()
+
+	return nil
+}
+
+// writeInitSegment produces the fMP4 initialization segment (moov box with
+// no samples) that both the DASH SegmentTemplate and the HLS EXT-X-MAP tag
+// reference before the first media segment.
+func (fp *FrameProcessor) writeInitSegment(mediaDir, firstFrame string, fps float64) error {
+	initPath := filepath.Join(mediaDir, "init.mp4")
+	args := []string{
+		"-y", "-loop", "1", "-i", firstFrame, "-frames:v", "1",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4", initPath,
+	}
+	return exec.Command("ffmpeg", args...).Run()
+}
+
+// writeMediaSegment encodes one chunk of source frames into a fragmented
+// MP4 segment, drawing the bounding boxes from the segment's first frame's
+// analysis result (see drawboxFilter) over every frame of the segment; the
+// annotations of the segment's other frames are not drawn.
+func (fp *FrameProcessor) writeMediaSegment(mediaDir, segName string, frames []string, results []*models.AnalysisResult, frameOffset int, fps float64) error {
+	segPath := filepath.Join(mediaDir, segName)
+	listPath := filepath.Join(mediaDir, segName+".txt")
+
+	var list strings.Builder
+	for _, f := range frames {
+		list.WriteString(fmt.Sprintf("file '%s'\nduration %f\n", f, 1.0/fps))
+	}
+	if len(frames) > 0 {
+		list.WriteString(fmt.Sprintf("file '%s'\n", frames[len(frames)-1]))
+	}
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+
+	filter := drawboxFilter(results, frameOffset, len(frames))
+
+	args := []string{
+		"-y", "-f", "concat", "-safe", "0", "-i", listPath,
+		"-vf", filter,
+		"-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4", segPath,
+	}
+	return exec.Command("ffmpeg", args...).Run()
+}
+
+// allowedDrawboxColors is the set of ffmpeg color names drawboxFilter will
+// pass through verbatim. Annotation.Color comes from the ML backend's JSON
+// response (a pluggable, network-reachable service per chunk2-2), so it
+// can't be interpolated into the ffmpeg filtergraph unsanitized - a crafted
+// value could inject extra filter stages (e.g. "red,movie=/etc/passwd").
+var allowedDrawboxColors = map[string]bool{
+	"red": true, "green": true, "blue": true, "yellow": true,
+	"orange": true, "white": true, "black": true, "cyan": true, "magenta": true,
+}
+
+// hexColorPattern matches a bare 6-digit hex color (ffmpeg's "RRGGBB" or
+// "0xRRGGBB" forms), the other shape Annotation.Color is allowed to take.
+var hexColorPattern = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{6}$`)
+
+// sanitizeDrawboxColor returns color unchanged if it's a known-safe ffmpeg
+// color (an allowlisted name or a bare hex triplet), and "red" otherwise -
+// anything else could smuggle extra filtergraph stages past drawbox.
+func sanitizeDrawboxColor(color string) string {
+	if allowedDrawboxColors[color] || hexColorPattern.MatchString(color) {
+		return color
+	}
+	return "red"
+}
+
+// drawboxFilter builds an ffmpeg filtergraph that burns in a box for every
+// annotation on the first frame of this segment, over the whole segment.
+// Falls back to a
+// no-op passthrough when there's nothing to draw, including when that frame
+// failed analysis (a nil entry in results).
+func drawboxFilter(results []*models.AnalysisResult, frameOffset, frameCount int) string {
+	if frameOffset >= len(results) || results[frameOffset] == nil {
+		return "null"
+	}
+
+	result := results[frameOffset]
+	if len(result.Annotations) == 0 {
+		return "null"
+	}
+
+	var filters []string
+	for _, a := range result.Annotations {
+		color := sanitizeDrawboxColor(a.Color)
+		filters = append(filters, fmt.Sprintf(
+			"drawbox=x=%d:y=%d:w=%d:h=%d:color=%s@0.8:t=2",
+			int(a.X), int(a.Y), int(a.Width), int(a.Height), color))
+	}
+	return strings.Join(filters, ",")
+}
+
+// writeVTTCue appends one WebVTT cue covering segmentIndex's time range,
+// listing the labels detected across the frames in that segment.
+func writeVTTCue(vtt *strings.Builder, segmentIndex int, segDuration float64, results []*models.AnalysisResult, start, end int) {
+	cueStart := time.Duration(float64(segmentIndex) * segDuration * float64(time.Second))
+	cueEnd := cueStart + time.Duration(segDuration*float64(time.Second))
+
+	labels := map[string]bool{}
+	for i := start; i < end && i < len(results); i++ {
+		if results[i] == nil {
+			continue
+		}
+		for _, a := range results[i].Annotations {
+			if a.Label != "" {
+				labels[a.Label] = true
+			}
+		}
+	}
+
+	text := "no events detected"
+	if len(labels) > 0 {
+		names := make([]string, 0, len(labels))
+		for l := range labels {
+			names = append(names, l)
+		}
+		text = strings.Join(names, ", ")
+	}
+
+	fmt.Fprintf(vtt, "%s --> %s\n%s\n\n", formatVTTTimestamp(cueStart), formatVTTTimestamp(cueEnd), text)
+}
+
+func formatVTTTimestamp(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	ms := int(d.Milliseconds()) % 1000
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+// GetManifestMPD returns a DASH MPD for jobID using SegmentTemplate so a
+// dash.js player can address segments by number without a prior playlist
+// fetch.
+func (fp *FrameProcessor) GetManifestMPD(jobID string) (string, error) {
+	job, err := fp.mediaJob(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	mpd := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" mediaPresentationDuration="PT%.0fS" minBufferTime="PT%.1fS">
+  <Period>
+    <AdaptationSet mimeType="video/mp4" segmentAlignment="true">
+      <SegmentTemplate media="seg-$Number$.m4s" initialization="init.mp4" startNumber="0" duration="%d" timescale="1"/>
+      <Representation id="0" bandwidth="1200000" codecs="avc1.42c01e" width="1280" height="720"/>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`, float64(job.segmentCount)*job.segmentSecs, job.segmentSecs, int(job.segmentSecs))
+
+	return mpd, nil
+}
+
+// GetMasterPlaylist returns an HLS playlist for jobID. fMP4 segments need
+// an EXT-X-MAP pointing at the shared init segment, so this is a single
+// media playlist rather than a true master/variant pair.
+func (fp *FrameProcessor) GetMasterPlaylist(jobID string) (string, error) {
+	job, err := fp.mediaJob(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(job.segmentSecs))
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	b.WriteString("#EXT-X-MAP:URI=\"init.mp4\"\n")
+
+	for i := 0; i < job.segmentCount; i++ {
+		fmt.Fprintf(&b, "#EXTINF:%.1f,\n", job.segmentSecs)
+		fmt.Fprintf(&b, "seg-%d.m4s\n", i)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+
+	return b.String(), nil
+}
+
+// GetMediaFile reads a file (init segment, media segment, or the WebVTT
+// sidecar) out of jobID's media directory for the manifest handlers to
+// stream back.
+func (fp *FrameProcessor) GetMediaFile(jobID, filename string) ([]byte, error) {
+	job, err := fp.mediaJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	// filename comes from a URL path param; restrict it to the basename so
+	// it can't be used to climb out of the media directory.
+	safeName := filepath.Base(filename)
+	return os.ReadFile(filepath.Join(job.mediaDir, safeName))
+}
+
+func (fp *FrameProcessor) mediaJob(jobID string) (*VideoJob, error) {
+	fp.mutex.RLock()
+	defer fp.mutex.RUnlock()
+
+	job, exists := fp.jobTracker[jobID]
+	if !exists {
+		return nil, fmt.Errorf("job not found")
+	}
+	if !job.ManifestReady {
+		return nil, fmt.Errorf("manifest not ready")
+	}
+	return job, nil
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/san-kum/motorcycle-cv/server/ml"
+	"github.com/san-kum/motorcycle-cv/server/models"
+)
+
+// FrameEvent describes one completed stage of a frame's processing, emitted
+// to whatever PipelineObserver is registered via FrameProcessor.SetObserver.
+// Stage is one of "process_frame" (the whole ProcessFrame call),
+// "analyze_frame" (the ml.Backend.AnalyzeFrame call within it) or
+// "dropped" (a frame evicted before reaching ProcessFrame; see RecordDrop).
+type FrameEvent struct {
+	Stage      string
+	ClientID   string
+	ImageBytes int
+	Timestamp  time.Time
+	Duration   time.Duration
+	Retries    int
+	Err        error
+	Result     *models.AnalysisResult
+}
+
+// PipelineObserver is a Clash-style traffic controller hook: a single
+// observer registered once at startup that sees every frame event without
+// ProcessFrame or ml.Client needing to know whether anything is listening.
+// Implementations are expected to return from ObserveFrame quickly - it's
+// called inline from the hot path via defer, not from a separate goroutine.
+type PipelineObserver interface {
+	ObserveFrame(event FrameEvent)
+}
+
+// SetObserver registers obs to receive a FrameEvent for every ProcessFrame
+// call. If the configured ml.Backend also supports SetAnalyzeObserver (only
+// ml.Client does; ml.GRPCBackend and ml.ONNXBackend don't instrument
+// AnalyzeFrame this way), SetObserver bridges its ml.AnalyzeEvent callbacks
+// into "analyze_frame"-stage FrameEvents on the same obs, so a single call
+// here wires up both layers - processor and ml package stay free of an
+// import cycle since ml only ever sees a plain func(ml.AnalyzeEvent).
+func (fp *FrameProcessor) SetObserver(obs PipelineObserver) {
+	fp.mutex.Lock()
+	fp.observer = obs
+	fp.mutex.Unlock()
+
+	if bridge, ok := fp.mlClient.(interface {
+		SetAnalyzeObserver(func(ml.AnalyzeEvent))
+	}); ok {
+		bridge.SetAnalyzeObserver(func(event ml.AnalyzeEvent) {
+			fp.observeFrame(FrameEvent{
+				Stage:      "analyze_frame",
+				ClientID:   event.ClientID,
+				ImageBytes: event.ImageBytes,
+				Timestamp:  time.Now(),
+				Duration:   event.Duration,
+				Retries:    event.Retries,
+				Err:        event.Err,
+			})
+		})
+	}
+}
+
+// RecordDrop reports a frame that frameBacklog evicted before it ever
+// reached ProcessFrame (see WebSocketHandler.dispatchFrame), so observers
+// tracking drop rate - ClientStatsObserver - still see it even though no
+// "process_frame" FrameEvent was ever produced for it.
+func (fp *FrameProcessor) RecordDrop(clientID string) {
+	fp.observeFrame(FrameEvent{
+		Stage:     "dropped",
+		ClientID:  clientID,
+		Timestamp: time.Now(),
+	})
+}
+
+func (fp *FrameProcessor) observeFrame(event FrameEvent) {
+	fp.mutex.RLock()
+	obs := fp.observer
+	fp.mutex.RUnlock()
+
+	if obs != nil {
+		obs.ObserveFrame(event)
+	}
+}
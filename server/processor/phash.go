@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+const phashSize = 32   // downscale to phashSize x phashSize before DCT
+const phashLowFreq = 8 // keep the top-left phashLowFreq x phashLowFreq DCT coefficients
+
+// computePHash computes a 64-bit perceptual hash (pHash) of the given
+// JPEG/PNG image: decode, downscale to a small grayscale image, run a 2D
+// DCT, take the top-left low-frequency coefficients (excluding the DC
+// term), and threshold each against their median to produce a bitset.
+// Unlike an MD5 of raw bytes, this is robust to recompression noise and
+// single-pixel changes, so near-duplicate frames hash identically.
+func computePHash(imageData []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	gray := toGrayscale(img)
+	small := downscale(gray, phashSize, phashSize)
+
+	matrix := make([][]float64, phashSize)
+	for y := 0; y < phashSize; y++ {
+		matrix[y] = make([]float64, phashSize)
+		for x := 0; x < phashSize; x++ {
+			matrix[y][x] = float64(small.GrayAt(x, y).Y)
+		}
+	}
+
+	dct := dct2D(matrix)
+
+	coeffs := make([]float64, 0, phashLowFreq*phashLowFreq-1)
+	for y := 0; y < phashLowFreq; y++ {
+		for x := 0; x < phashLowFreq; x++ {
+			if x == 0 && y == 0 {
+				continue // exclude the DC term, which just reflects average brightness
+			}
+			coeffs = append(coeffs, dct[y][x])
+		}
+	}
+
+	median := medianOf(coeffs)
+
+	var hash uint64
+	for i, v := range coeffs {
+		if i >= 64 {
+			break
+		}
+		if v > median {
+			hash |= 1 << uint(i)
+		}
+	}
+
+	return hash, nil
+}
+
+// hammingDistance returns the number of differing bits between two
+// perceptual hashes.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func toGrayscale(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+	return gray
+}
+
+func downscale(img *image.Gray, w, h int) *image.Gray {
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// dct2D computes a naive 2D type-II discrete cosine transform. phashSize
+// is small (32x32) so the O(n^4) direct form is fast enough here; a
+// separable/FFT-based DCT isn't worth the complexity at this scale.
+func dct2D(matrix [][]float64) [][]float64 {
+	n := len(matrix)
+	result := make([][]float64, n)
+	for u := 0; u < n; u++ {
+		result[u] = make([]float64, n)
+		for v := 0; v < n; v++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				for y := 0; y < n; y++ {
+					sum += matrix[x][y] *
+						dctCos(x, u, n) *
+						dctCos(y, v, n)
+				}
+			}
+			result[u][v] = sum * dctScale(u, n) * dctScale(v, n)
+		}
+	}
+	return result
+}
+
+func dctCos(pos, freq, n int) float64 {
+	return math.Cos(math.Pi / float64(n) * (float64(pos) + 0.5) * float64(freq))
+}
+
+func dctScale(freq, n int) float64 {
+	if freq == 0 {
+		return 1.0 / math.Sqrt(float64(n))
+	}
+	return math.Sqrt(2.0 / float64(n))
+}
+
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j-1] > sorted[j]; j-- {
+			sorted[j-1], sorted[j] = sorted[j], sorted[j-1]
+		}
+	}
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
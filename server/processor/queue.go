@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"container/heap"
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -9,41 +11,81 @@ import (
 )
 
 type ProcessingQueue struct {
-	items      chan *QueueItem
+	backend    QueueBackend
 	workers    int
 	workerFunc func(*QueueItem)
+	workChans  []chan *QueueItem
 	wg         sync.WaitGroup
 	shutdown   chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
 	isRunning  bool
 	mutex      sync.RWMutex
+	capacity   int
 }
 
 type QueueItem struct {
 	Request    *models.FrameRequest
 	ResultChan chan *ProcessingResult
 	StartTime  time.Time
-	Priority   int // Higher values = higher priority
+	Priority   int // Base priority; higher values = higher priority
+	ClientID   string
+
+	// Ctx is cancelled when the request this frame came from goes away
+	// (WebSocket closed, HTTP request context done). processFrame passes it
+	// to AnalyzeFrame so an abandoned frame doesn't keep an HTTP call to the
+	// ML backend alive. Defaults to context.Background() if unset.
+	Ctx context.Context
+
+	index int // heap index, maintained by container/heap
 }
 
 type ProcessingResult struct {
-	Analysis *models.AnalysisResult
-	Error    error
+	Analysis      *models.AnalysisResult
+	Error         error
+	ResourceUsage *ResourceUsage
+}
+
+// effectivePriority applies aging so long-waiting items don't starve behind
+// a steady stream of fresh high-priority ones.
+func (qi *QueueItem) effectivePriority(now time.Time) int {
+	waited := now.Sub(qi.StartTime)
+	aged := int(waited / (500 * time.Millisecond))
+	return qi.Priority + aged
 }
 
 func NewProcessingQueue(queueSize, workers int, workerFunc func(*QueueItem)) *ProcessingQueue {
+	return NewProcessingQueueWithBackend(NewChannelBackend(queueSize), queueSize, workers, workerFunc)
+}
+
+// NewProcessingQueueWithBackend wires ProcessingQueue's worker pool to an
+// arbitrary QueueBackend (in-process channel, or a distributed backend
+// like NATS JetStream), so the workers and dispatch loop below don't need
+// to know whether work items live in a local heap or a shared stream.
+func NewProcessingQueueWithBackend(backend QueueBackend, capacity, workers int, workerFunc func(*QueueItem)) *ProcessingQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	queue := &ProcessingQueue{
-		items:      make(chan *QueueItem, queueSize),
+		backend:    backend,
 		workers:    workers,
 		workerFunc: workerFunc,
+		workChans:  make([]chan *QueueItem, workers),
 		shutdown:   make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
 		isRunning:  true,
+		capacity:   capacity,
 	}
 
 	for i := 0; i < workers; i++ {
+		queue.workChans[i] = make(chan *QueueItem)
 		queue.wg.Add(1)
 		go queue.worker(i)
 	}
 
+	queue.wg.Add(1)
+	go queue.dispatcher()
+
 	return queue
 }
 
@@ -52,7 +94,7 @@ func (pq *ProcessingQueue) worker(id int) {
 
 	for {
 		select {
-		case item := <-pq.items:
+		case item := <-pq.workChans[id]:
 			if item != nil {
 				func() {
 					defer func() {
@@ -75,6 +117,42 @@ func (pq *ProcessingQueue) worker(id int) {
 	}
 }
 
+// dispatcher pulls the highest (aged, fairness-weighted) priority item off
+// the backend and hands it to the next free worker. The backend itself
+// (channel heap or NATS) is responsible for ordering/blocking.
+func (pq *ProcessingQueue) dispatcher() {
+	defer pq.wg.Done()
+
+	for {
+		item := pq.backend.Next(pq.ctx)
+		if item == nil {
+			if pq.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case pq.workChans[pq.nextWorker()] <- item:
+		case <-pq.shutdown:
+			select {
+			case item.ResultChan <- &ProcessingResult{
+				Error: fmt.Errorf("processing cancelled - queue shutting down"),
+			}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+var workerRoundRobin uint64
+
+func (pq *ProcessingQueue) nextWorker() int {
+	workerRoundRobin++
+	return int(workerRoundRobin % uint64(pq.workers))
+}
+
 func (pq *ProcessingQueue) Enqueue(item *QueueItem) bool {
 	pq.mutex.RLock()
 	if !pq.isRunning {
@@ -83,20 +161,15 @@ func (pq *ProcessingQueue) Enqueue(item *QueueItem) bool {
 	}
 	pq.mutex.RUnlock()
 
-	select {
-	case pq.items <- item:
-		return true
-	default:
-		return false
-	}
+	return pq.backend.Enqueue(item)
 }
 
 func (pq *ProcessingQueue) Size() int {
-	return len(pq.items)
+	return pq.backend.Size()
 }
 
 func (pq *ProcessingQueue) Capacity() int {
-	return cap(pq.items)
+	return pq.capacity
 }
 
 func (pq *ProcessingQueue) IsRunning() bool {
@@ -119,6 +192,7 @@ func (pq *ProcessingQueue) Shutdown(timeout time.Duration) error {
 	pq.mutex.Unlock()
 
 	close(pq.shutdown)
+	pq.cancel()
 
 	done := make(chan struct{})
 	go func() {
@@ -126,37 +200,39 @@ func (pq *ProcessingQueue) Shutdown(timeout time.Duration) error {
 		close(done)
 	}()
 
+	var err error
 	select {
 	case <-done:
-		close(pq.items)
-		return nil
 	case <-time.After(timeout):
-		close(pq.items)
-		return fmt.Errorf("shutdown timeout exceeded")
+		err = fmt.Errorf("shutdown timeout exceeded")
+	}
+
+	pq.DrainQueue()
+	if closeErr := pq.backend.Close(); closeErr != nil && err == nil {
+		err = closeErr
 	}
+	return err
 }
 
 func (pq *ProcessingQueue) DrainQueue() int {
-	pq.mutex.RLock()
-	defer pq.mutex.RUnlock()
-
 	drained := 0
 
+	drainCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
 	for {
+		item := pq.backend.Next(drainCtx)
+		if item == nil {
+			return drained
+		}
+
 		select {
-		case item := <-pq.items:
-			if item != nil {
-				select {
-				case item.ResultChan <- &ProcessingResult{
-					Error: fmt.Errorf("processing cancelled - queue shutting down"),
-				}:
-				default:
-				}
-				drained++
-			}
+		case item.ResultChan <- &ProcessingResult{
+			Error: fmt.Errorf("processing cancelled - queue shutting down"),
+		}:
 		default:
-			return drained
 		}
+		drained++
 	}
 }
 
@@ -164,13 +240,27 @@ func (pq *ProcessingQueue) GetQueueStats() QueueStats {
 	pq.mutex.RLock()
 	defer pq.mutex.RUnlock()
 
+	size := pq.Size()
+	cap := pq.Capacity()
+
 	return QueueStats{
-		CurrentSize:        pq.Size(),
-		MaxCapacity:        pq.Capacity(),
+		CurrentSize:        size,
+		MaxCapacity:        cap,
 		ActiveWorkers:      pq.workers,
 		IsRunning:          pq.isRunning,
-		UtilizationPercent: float64(pq.Size()) / float64(pq.Capacity()) * 100,
+		UtilizationPercent: float64(size) / float64(cap) * 100,
+	}
+}
+
+// Remove removes all queued items belonging to clientID, used to shed load
+// from a single misbehaving client without touching everyone else. Only
+// supported by backends that expose local queue state (the channel
+// backend); distributed backends like NATS return 0.
+func (pq *ProcessingQueue) Remove(clientID string) int {
+	if b, ok := pq.backend.(*channelBackend); ok {
+		return b.heap.Remove(clientID)
 	}
+	return 0
 }
 
 type QueueStats struct {
@@ -181,36 +271,54 @@ type QueueStats struct {
 	UtilizationPercent float64 `json:"utilization_percent"`
 }
 
+// PriorityQueue is a container/heap-backed max-heap of QueueItems keyed by
+// effective (aged) priority, with per-client weighted-fair queuing so a
+// single noisy client can't starve the rest of the queue.
 type PriorityQueue struct {
-	items []*QueueItem
-	mutex sync.RWMutex
+	items    itemHeap
+	byClient map[string]int
+	capacity int
+	mutex    sync.Mutex
 }
 
-func NewPriorityQueue() *PriorityQueue {
-	return &PriorityQueue{
-		items: make([]*QueueItem, 0),
+func NewPriorityQueue(capacity int) *PriorityQueue {
+	pq := &PriorityQueue{
+		items:    make(itemHeap, 0),
+		byClient: make(map[string]int),
+		capacity: capacity,
 	}
+	heap.Init(&pq.items)
+	return pq
 }
 
-func (pq *PriorityQueue) Push(item *QueueItem) {
+// Push enqueues item, returning false if the queue is at capacity.
+func (pq *PriorityQueue) Push(item *QueueItem) bool {
 	pq.mutex.Lock()
 	defer pq.mutex.Unlock()
 
-	inserted := false
-	for i, existing := range pq.items {
-		if item.Priority > existing.Priority {
-			pq.items = append(pq.items[:i], append([]*QueueItem{item}, pq.items[i:]...)...)
-			inserted = true
-			break
-		}
+	if pq.capacity > 0 && len(pq.items) >= pq.capacity {
+		return false
 	}
 
-	if !inserted {
-		pq.items = append(pq.items, item)
+	heap.Push(&pq.items, item)
+	pq.byClient[item.ClientID]++
+	return true
+}
+
+// Peek returns the item that would be dequeued next, without removing it.
+func (pq *PriorityQueue) Peek() *QueueItem {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+
+	if len(pq.items) == 0 {
+		return nil
 	}
+	return pq.items[pq.bestIndexLocked()]
 }
 
-func (pq *PriorityQueue) Pop() *QueueItem {
+// PopNext selects, removes and returns the item with the highest effective
+// (aged, fairness-weighted) priority. Returns nil if the queue is empty.
+func (pq *PriorityQueue) PopNext() *QueueItem {
 	pq.mutex.Lock()
 	defer pq.mutex.Unlock()
 
@@ -218,19 +326,119 @@ func (pq *PriorityQueue) Pop() *QueueItem {
 		return nil
 	}
 
-	item := pq.items[0]
-	pq.items = pq.items[1:]
+	idx := pq.bestIndexLocked()
+	item := pq.items[idx]
+	heap.Remove(&pq.items, idx)
+	pq.byClient[item.ClientID]--
+	if pq.byClient[item.ClientID] <= 0 {
+		delete(pq.byClient, item.ClientID)
+	}
 	return item
 }
 
+// bestIndexLocked scans for the item with the highest fairness-adjusted
+// effective priority. The heap ordering keeps raw priority roughly sorted,
+// so this stays cheap in practice even though it's O(n) worst case; queue
+// sizes here are small (MaxQueueSize, typically in the hundreds).
+func (pq *PriorityQueue) bestIndexLocked() int {
+	now := time.Now()
+	best := 0
+	bestScore := pq.scoreLocked(pq.items[0], now)
+
+	for i := 1; i < len(pq.items); i++ {
+		score := pq.scoreLocked(pq.items[i], now)
+		if score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// scoreLocked penalizes clients that currently have many items in flight so
+// that a single noisy client's backlog doesn't crowd out everyone else.
+func (pq *PriorityQueue) scoreLocked(item *QueueItem, now time.Time) float64 {
+	clientLoad := pq.byClient[item.ClientID]
+	fairnessPenalty := float64(clientLoad) * 0.5
+	return float64(item.effectivePriority(now)) - fairnessPenalty
+}
+
 func (pq *PriorityQueue) Len() int {
-	pq.mutex.RLock()
-	defer pq.mutex.RUnlock()
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
 	return len(pq.items)
 }
 
+func (pq *PriorityQueue) Capacity() int {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+	return pq.capacity
+}
+
+// Remove drops every queued item belonging to clientID and fails them with
+// an error on their ResultChan, returning the count removed.
+func (pq *PriorityQueue) Remove(clientID string) int {
+	pq.mutex.Lock()
+	defer pq.mutex.Unlock()
+
+	removed := 0
+	for i := 0; i < len(pq.items); {
+		if pq.items[i].ClientID == clientID {
+			item := pq.items[i]
+			heap.Remove(&pq.items, i)
+			select {
+			case item.ResultChan <- &ProcessingResult{
+				Error: fmt.Errorf("request removed from queue"),
+			}:
+			default:
+			}
+			removed++
+			continue
+		}
+		i++
+	}
+
+	if removed > 0 {
+		delete(pq.byClient, clientID)
+	}
+	return removed
+}
+
 func (pq *PriorityQueue) Clear() {
 	pq.mutex.Lock()
 	defer pq.mutex.Unlock()
 	pq.items = pq.items[:0]
+	pq.byClient = make(map[string]int)
+}
+
+// itemHeap implements container/heap.Interface, ordered by raw Priority.
+// Effective (aged, fairness-adjusted) ordering is computed separately in
+// PriorityQueue.bestIndexLocked since it depends on wall-clock time and
+// per-client load rather than a static key.
+type itemHeap []*QueueItem
+
+func (h itemHeap) Len() int { return len(h) }
+
+func (h itemHeap) Less(i, j int) bool { return h[i].Priority > h[j].Priority }
+
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *itemHeap) Push(x any) {
+	item := x.(*QueueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *itemHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
 }
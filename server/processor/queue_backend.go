@@ -0,0 +1,334 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/san-kum/motorcycle-cv/server/models"
+	"go.uber.org/zap"
+)
+
+// QueueBackend decouples ProcessingQueue's dispatch mechanism from where
+// work items actually live, so a single process's in-memory heap and a
+// NATS JetStream-backed shared queue can be swapped in without touching
+// FrameProcessor or the worker pool.
+type QueueBackend interface {
+	// Enqueue submits item for processing. Returns false if the backend
+	// is full or not accepting new work.
+	Enqueue(item *QueueItem) bool
+
+	// Next blocks (respecting ctx) until a work item is available and
+	// returns it, or returns nil if ctx is cancelled.
+	Next(ctx context.Context) *QueueItem
+
+	// Size reports the current number of outstanding items.
+	Size() int
+
+	Close() error
+}
+
+// channelBackend is the original single-process in-memory backend,
+// wrapping the PriorityQueue from queue.go.
+type channelBackend struct {
+	heap *PriorityQueue
+}
+
+func NewChannelBackend(capacity int) QueueBackend {
+	return &channelBackend{heap: NewPriorityQueue(capacity)}
+}
+
+func (b *channelBackend) Enqueue(item *QueueItem) bool { return b.heap.Push(item) }
+
+func (b *channelBackend) Next(ctx context.Context) *QueueItem {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if item := b.heap.PopNext(); item != nil {
+			return item
+		}
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *channelBackend) Size() int { return b.heap.Len() }
+
+func (b *channelBackend) Close() error { return nil }
+
+// natsQueueItem is the wire representation of a QueueItem published to
+// JetStream: everything except the local ResultChan, which can't cross
+// process boundaries. Results are correlated back via ReplySubject.
+type natsQueueItem struct {
+	Request      *models.FrameRequest `json:"request"`
+	StartTime    time.Time            `json:"start_time"`
+	Priority     int                  `json:"priority"`
+	ClientID     string               `json:"client_id"`
+	ReplySubject string               `json:"reply_subject"`
+}
+
+// NATSBackendConfig configures the JetStream-backed queue backend.
+type NATSBackendConfig struct {
+	URL           string
+	StreamName    string
+	Subject       string
+	ConsumerGroup string
+	MaxInFlight   int
+	MaxDeliver    int
+	AckWait       time.Duration
+	DLQSubject    string
+}
+
+// natsBackend publishes QueueItems to a JetStream stream so multiple
+// motorcycle-cv instances can share one work queue with at-least-once
+// delivery, durable consumers, and consumer-group load balancing across
+// processes. Poison frames that exceed MaxDeliver are redirected to
+// DLQSubject instead of being redelivered forever.
+type natsBackend struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	sub    *nats.Subscription
+	config NATSBackendConfig
+	logger *zap.Logger
+
+	// maxDeliver is the resolved value actually passed to PullSubscribe
+	// (config.MaxDeliver defaulted), kept so Next can tell whether a
+	// failed message has exhausted its redeliveries and belongs in the DLQ.
+	maxDeliver int
+}
+
+func NewNATSBackend(config NATSBackendConfig, logger *zap.Logger) (QueueBackend, error) {
+	conn, err := nats.Connect(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     config.StreamName,
+		Subjects: []string{config.Subject},
+	})
+	if err != nil && err != nats.ErrStreamNameAlreadyInUse {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ensure JetStream stream: %w", err)
+	}
+
+	maxDeliver := config.MaxDeliver
+	if maxDeliver <= 0 {
+		maxDeliver = 5
+	}
+	ackWait := config.AckWait
+	if ackWait <= 0 {
+		ackWait = 30 * time.Second
+	}
+
+	sub, err := js.PullSubscribe(config.Subject, config.ConsumerGroup,
+		nats.MaxDeliver(maxDeliver),
+		nats.AckWait(ackWait),
+		nats.MaxAckPending(config.MaxInFlight),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create durable consumer: %w", err)
+	}
+
+	return &natsBackend{
+		conn:       conn,
+		js:         js,
+		sub:        sub,
+		config:     config,
+		logger:     logger,
+		maxDeliver: maxDeliver,
+	}, nil
+}
+
+func (b *natsBackend) Enqueue(item *QueueItem) bool {
+	replySubject := fmt.Sprintf("%s.reply.%s.%d", b.config.Subject, item.ClientID, item.StartTime.UnixNano())
+
+	payload := natsQueueItem{
+		Request:      item.Request,
+		StartTime:    item.StartTime,
+		Priority:     item.Priority,
+		ClientID:     item.ClientID,
+		ReplySubject: replySubject,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		b.logger.Error("Failed to marshal queue item for NATS", zap.Error(err))
+		return false
+	}
+
+	// Subscribe before publishing: core NATS doesn't buffer for late
+	// subscribers, so a fast consumer could publish its reply before this
+	// subscription existed, losing it and leaving awaitResult to spuriously
+	// time out after 30s despite the frame having been processed correctly.
+	replySub, err := b.conn.SubscribeSync(replySubject)
+	if err != nil {
+		b.logger.Error("Failed to subscribe for NATS reply", zap.Error(err))
+		return false
+	}
+
+	if _, err := b.js.Publish(b.config.Subject, data); err != nil {
+		b.logger.Error("Failed to publish frame to JetStream", zap.Error(err))
+		replySub.Unsubscribe()
+		return false
+	}
+
+	go b.awaitResult(replySub, item)
+
+	return true
+}
+
+func (b *natsBackend) awaitResult(sub *nats.Subscription, item *QueueItem) {
+	defer sub.Unsubscribe()
+
+	msg, err := sub.NextMsg(30 * time.Second)
+	if err != nil {
+		select {
+		case item.ResultChan <- &ProcessingResult{Error: fmt.Errorf("no reply from NATS worker: %w", err)}:
+		default:
+		}
+		return
+	}
+
+	var result ProcessingResult
+	if err := json.Unmarshal(msg.Data, &result); err != nil {
+		select {
+		case item.ResultChan <- &ProcessingResult{Error: fmt.Errorf("failed to decode NATS reply: %w", err)}:
+		default:
+		}
+		return
+	}
+
+	select {
+	case item.ResultChan <- &result:
+	default:
+	}
+}
+
+// Next pulls the next batch of messages from the durable consumer and
+// decodes the first one into a QueueItem (restoring a local ResultChan the
+// worker uses to publish its result back to ReplySubject). The message is
+// NOT acked here - it's only decoded, not processed yet - so a crash before
+// the worker finishes wouldn't otherwise lose the frame. Acking (or
+// NAK/Term-ing on failure) happens in the goroutine below, once the result
+// is actually known. Poison messages (delivery count over MaxDeliver) are
+// routed to DLQSubject instead of looping forever.
+func (b *natsBackend) Next(ctx context.Context) *QueueItem {
+	msgs, err := b.sub.Fetch(1, nats.Context(ctx))
+	if err != nil || len(msgs) == 0 {
+		return nil
+	}
+	msg := msgs[0]
+
+	var payload natsQueueItem
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		b.logger.Error("Failed to decode NATS frame, sending to DLQ", zap.Error(err))
+		b.deadLetter(msg.Data)
+		msg.Term()
+		return nil
+	}
+
+	resultChan := make(chan *ProcessingResult, 1)
+	item := &QueueItem{
+		Request:    payload.Request,
+		ResultChan: resultChan,
+		StartTime:  payload.StartTime,
+		Priority:   payload.Priority,
+		ClientID:   payload.ClientID,
+		// Ctx can't cross the NATS JetStream message boundary any more than
+		// ResultChan can (see natsQueueItem), so a frame dequeued here always
+		// processes with a fresh background context rather than the
+		// originating request's.
+		Ctx: context.Background(),
+	}
+
+	go func() {
+		result := <-resultChan
+
+		if result.Error != nil {
+			b.nakOrDeadLetter(msg, result.Error)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			b.logger.Error("Failed to marshal NATS result", zap.Error(err))
+			b.nakOrDeadLetter(msg, err)
+			return
+		}
+		if err := b.conn.Publish(payload.ReplySubject, data); err != nil {
+			b.logger.Error("Failed to publish NATS reply", zap.Error(err))
+			b.nakOrDeadLetter(msg, err)
+			return
+		}
+
+		// Only ack once the result has actually been produced and the reply
+		// published - acking any earlier would let JetStream drop a frame
+		// that crashes the process mid-processing, since an acked message is
+		// never redelivered.
+		if err := msg.AckSync(); err != nil {
+			b.logger.Warn("Failed to ack NATS message", zap.Error(err))
+		}
+	}()
+
+	return item
+}
+
+// nakOrDeadLetter is called once a dequeued frame is known to have failed
+// processing. A message still under MaxDeliver is NAK'd so JetStream
+// redelivers it to another consumer; one that has exhausted its
+// redeliveries is routed to DLQSubject and terminated so it stops being
+// retried.
+func (b *natsBackend) nakOrDeadLetter(msg *nats.Msg, cause error) {
+	if meta, metaErr := msg.Metadata(); metaErr == nil && b.maxDeliver > 0 && int(meta.NumDelivered) >= b.maxDeliver {
+		b.logger.Error("NATS frame exhausted MaxDeliver, sending to DLQ",
+			zap.Error(cause), zap.Uint64("delivered", meta.NumDelivered))
+		b.deadLetter(msg.Data)
+		msg.Term()
+		return
+	}
+
+	b.logger.Warn("NATS frame processing failed, NAKing for redelivery", zap.Error(cause))
+	if err := msg.Nak(); err != nil {
+		b.logger.Error("Failed to NAK NATS message", zap.Error(err))
+	}
+}
+
+func (b *natsBackend) deadLetter(data []byte) {
+	if b.config.DLQSubject == "" {
+		return
+	}
+	if err := b.conn.Publish(b.config.DLQSubject, data); err != nil {
+		b.logger.Error("Failed to publish to DLQ", zap.Error(err))
+	}
+}
+
+func (b *natsBackend) Size() int {
+	info, err := b.sub.ConsumerInfo()
+	if err != nil {
+		return 0
+	}
+	return int(info.NumPending)
+}
+
+func (b *natsBackend) Close() error {
+	if b.sub != nil {
+		b.sub.Unsubscribe()
+	}
+	b.conn.Close()
+	return nil
+}
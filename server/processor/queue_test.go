@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func newQueueItem(clientID string, priority int) *QueueItem {
+	return &QueueItem{
+		ResultChan: make(chan *ProcessingResult, 1),
+		StartTime:  time.Now(),
+		Priority:   priority,
+		ClientID:   clientID,
+	}
+}
+
+func TestPriorityQueuePopsHighestPriorityFirst(t *testing.T) {
+	pq := NewPriorityQueue(0)
+
+	pq.Push(newQueueItem("a", 1))
+	pq.Push(newQueueItem("b", 10))
+	pq.Push(newQueueItem("c", 5))
+
+	item := pq.PopNext()
+	if item == nil || item.ClientID != "b" {
+		t.Fatalf("PopNext() = %+v, want the priority-10 item from client b", item)
+	}
+}
+
+func TestPriorityQueueRespectsCapacity(t *testing.T) {
+	pq := NewPriorityQueue(1)
+
+	if ok := pq.Push(newQueueItem("a", 1)); !ok {
+		t.Fatal("Push() on empty queue returned false")
+	}
+	if ok := pq.Push(newQueueItem("b", 1)); ok {
+		t.Fatal("Push() on full queue returned true, want false")
+	}
+	if got := pq.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1", got)
+	}
+}
+
+func TestPriorityQueueAgingPromotesOlderItems(t *testing.T) {
+	pq := NewPriorityQueue(0)
+
+	older := newQueueItem("a", 1)
+	older.StartTime = time.Now().Add(-2 * time.Second)
+	pq.Push(older)
+
+	newer := newQueueItem("b", 1)
+	pq.Push(newer)
+
+	item := pq.PopNext()
+	if item == nil || item.ClientID != "a" {
+		t.Fatalf("PopNext() = %+v, want the older item from client a to have aged ahead", item)
+	}
+}
+
+func TestPriorityQueueFairnessPenalizesBusyClient(t *testing.T) {
+	pq := NewPriorityQueue(0)
+
+	// Three same-priority items from "busy" queue first, so it accrues
+	// fairness load, then one same-priority item from "quiet" - quiet
+	// should be preferred despite arriving last.
+	pq.Push(newQueueItem("busy", 5))
+	pq.Push(newQueueItem("busy", 5))
+	pq.Push(newQueueItem("busy", 5))
+	pq.Push(newQueueItem("quiet", 5))
+
+	item := pq.PopNext()
+	if item == nil || item.ClientID != "quiet" {
+		t.Fatalf("PopNext() = %+v, want the quiet client's item preferred over the busy client's backlog", item)
+	}
+}
+
+func TestPriorityQueueRemoveDrainsClientAndFailsResults(t *testing.T) {
+	pq := NewPriorityQueue(0)
+
+	pq.Push(newQueueItem("a", 1))
+	target := newQueueItem("a", 1)
+	pq.Push(target)
+	pq.Push(newQueueItem("b", 1))
+
+	removed := pq.Remove("a")
+	if removed != 2 {
+		t.Errorf("Remove() = %d, want 2", removed)
+	}
+	if got := pq.Len(); got != 1 {
+		t.Errorf("Len() after Remove() = %d, want 1", got)
+	}
+
+	select {
+	case result := <-target.ResultChan:
+		if result.Error == nil {
+			t.Error("Remove() delivered a result with no error")
+		}
+	default:
+		t.Error("Remove() did not deliver a result on the removed item's ResultChan")
+	}
+}
+
+func TestPriorityQueuePopNextOnEmptyReturnsNil(t *testing.T) {
+	pq := NewPriorityQueue(0)
+	if item := pq.PopNext(); item != nil {
+		t.Errorf("PopNext() on empty queue = %+v, want nil", item)
+	}
+}
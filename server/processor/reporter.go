@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const reportInterval = 30 * time.Second
+const latencyHistoryCap = 2000
+
+// statsReporter runs as a background goroutine that periodically logs and
+// exposes cumulative and rate-based throughput stats: frames/sec, bytes/sec
+// of decoded image data, cache hit ratio, dedup skip ratio, and latency
+// percentiles. The EWMA in updateLatencyStats hides tail latency, which
+// matters for real-time riding feedback SLAs, so this keeps a bounded
+// window of recent latencies to compute p95/p99 from.
+type statsReporter struct {
+	logger *zap.Logger
+
+	mutex          sync.Mutex
+	latencies      []float64 // recent latencies in ms, ring-buffered
+	latencyCursor  int
+	lastFrames     int64
+	lastBytes      int64
+	lastCacheHits  int64
+	lastCacheTotal int64
+	lastReportTime time.Time
+
+	stop chan struct{}
+}
+
+func newStatsReporter(logger *zap.Logger) *statsReporter {
+	return &statsReporter{
+		logger:         logger,
+		latencies:      make([]float64, 0, latencyHistoryCap),
+		lastReportTime: time.Now(),
+		stop:           make(chan struct{}),
+	}
+}
+
+func (r *statsReporter) recordLatency(ms float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.latencies) < latencyHistoryCap {
+		r.latencies = append(r.latencies, ms)
+	} else {
+		r.latencies[r.latencyCursor] = ms
+		r.latencyCursor = (r.latencyCursor + 1) % latencyHistoryCap
+	}
+}
+
+func (r *statsReporter) percentiles() (p50, p95, p99 float64) {
+	r.mutex.Lock()
+	samples := append([]float64(nil), r.latencies...)
+	r.mutex.Unlock()
+
+	if len(samples) == 0 {
+		return 0, 0, 0
+	}
+
+	sort.Float64s(samples)
+	p50 = percentileOf(samples, 0.50)
+	p95 = percentileOf(samples, 0.95)
+	p99 = percentileOf(samples, 0.99)
+	return
+}
+
+func percentileOf(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// run logs a structured, human-readable throughput line every
+// reportInterval until stopped.
+func (r *statsReporter) run(fp *FrameProcessor) {
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.report(fp)
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *statsReporter) report(fp *FrameProcessor) {
+	stats := fp.GetStats()
+
+	r.mutex.Lock()
+	elapsed := time.Since(r.lastReportTime).Seconds()
+	framesDelta := stats.TotalProcessed - r.lastFrames
+	bytesDelta := stats.BytesProcessed - r.lastBytes
+	cacheHitsDelta := stats.CacheHits - r.lastCacheHits
+	cacheTotalDelta := (stats.CacheHits + stats.CacheMisses) - r.lastCacheTotal
+
+	r.lastFrames = stats.TotalProcessed
+	r.lastBytes = stats.BytesProcessed
+	r.lastCacheHits = stats.CacheHits
+	r.lastCacheTotal = stats.CacheHits + stats.CacheMisses
+	r.lastReportTime = time.Now()
+	r.mutex.Unlock()
+
+	if elapsed <= 0 {
+		elapsed = reportInterval.Seconds()
+	}
+
+	framesPerSec := float64(framesDelta) / elapsed
+	bytesPerSec := float64(bytesDelta) / elapsed
+
+	var windowCacheRatio float64
+	if cacheTotalDelta > 0 {
+		windowCacheRatio = float64(cacheHitsDelta) / float64(cacheTotalDelta) * 100
+	}
+
+	var cumulativeCacheRatio float64
+	if stats.CacheHits+stats.CacheMisses > 0 {
+		cumulativeCacheRatio = float64(stats.CacheHits) / float64(stats.CacheHits+stats.CacheMisses) * 100
+	}
+
+	var dedupRatio float64
+	if stats.TotalProcessed > 0 {
+		dedupRatio = float64(stats.DedupSkips) / float64(stats.TotalProcessed) * 100
+	}
+
+	p50, p95, p99 := r.percentiles()
+
+	r.logger.Info("Processor throughput",
+		zap.String("frames_per_sec", fmt.Sprintf("%.1f/s", framesPerSec)),
+		zap.String("throughput", humanizeBytesPerSec(bytesPerSec)),
+		zap.String("cache_hit_ratio_window", fmt.Sprintf("%.1f%%", windowCacheRatio)),
+		zap.String("cache_hit_ratio_cumulative", fmt.Sprintf("%.1f%%", cumulativeCacheRatio)),
+		zap.String("dedup_skip_ratio", fmt.Sprintf("%.1f%%", dedupRatio)),
+		zap.String("latency_p50", fmt.Sprintf("%.0fms", p50)),
+		zap.String("latency_p95", fmt.Sprintf("%.0fms", p95)),
+		zap.String("latency_p99", fmt.Sprintf("%.0fms", p99)),
+		zap.Int64("total_processed", stats.TotalProcessed),
+		zap.String("bytes_processed", humanizeBytes(stats.BytesProcessed)),
+	)
+}
+
+func (r *statsReporter) Stop() {
+	close(r.stop)
+}
+
+// humanizeBytes renders a byte count using KB/MB/GB suffixes.
+func humanizeBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// humanizeBytesPerSec renders a byte rate using KB/s, MB/s, GB/s suffixes.
+func humanizeBytesPerSec(bytesPerSec float64) string {
+	return humanizeBytes(int64(bytesPerSec)) + "/s"
+}
+
+// humanizeCount renders a count using k/M suffixes.
+func humanizeCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// CgroupVersion identifies which cgroup hierarchy is mounted on the host.
+type CgroupVersion int
+
+const (
+	CgroupUnsupported CgroupVersion = iota
+	CgroupV1
+	CgroupV2
+)
+
+const cgroupV2Root = "/sys/fs/cgroup"
+const cgroupV1MemoryRoot = "/sys/fs/cgroup/memory"
+const cgroupV1CPURoot = "/sys/fs/cgroup/cpu,cpuacct"
+
+// ResourceMonitor places out-of-process ML worker subprocesses into a
+// dedicated cgroup slice and reads back real per-frame resource cost
+// (peak memory, CPU time, throttling, pids) instead of just wall-clock
+// latency. It detects cgroup v1 vs v2 at startup and no-ops cleanly on
+// non-Linux hosts or hosts without cgroup delegation.
+type ResourceMonitor struct {
+	logger  *zap.Logger
+	version CgroupVersion
+	root    string
+
+	mutex     sync.Mutex
+	slices    map[string]*cgroupSlice
+	throttled atomic.Int64
+	sampled   atomic.Int64
+}
+
+type cgroupSlice struct {
+	name string
+	dir  string
+}
+
+// ResourceUsage captures metered cost for a single out-of-process job.
+type ResourceUsage struct {
+	PeakMemoryBytes  int64   `json:"peak_memory_bytes"`
+	CPUUsageUsec     int64   `json:"cpu_usage_usec"`
+	CPUThrottledUsec int64   `json:"cpu_throttled_usec"`
+	ThrottleRatio    float64 `json:"throttle_ratio"`
+	PIDsCurrent      int64   `json:"pids_current"`
+}
+
+func NewResourceMonitor(logger *zap.Logger) *ResourceMonitor {
+	rm := &ResourceMonitor{
+		logger: logger,
+		slices: make(map[string]*cgroupSlice),
+	}
+
+	rm.version, rm.root = detectCgroupVersion()
+
+	switch rm.version {
+	case CgroupV2:
+		logger.Info("Resource monitor using cgroup v2", zap.String("root", rm.root))
+	case CgroupV1:
+		logger.Info("Resource monitor using cgroup v1", zap.String("root", rm.root))
+	default:
+		logger.Info("Cgroup metering unavailable, ResourceMonitor will no-op",
+			zap.String("os", runtime.GOOS))
+	}
+
+	return rm
+}
+
+func detectCgroupVersion() (CgroupVersion, string) {
+	if runtime.GOOS != "linux" {
+		return CgroupUnsupported, ""
+	}
+
+	if info, err := os.Stat(filepath.Join(cgroupV2Root, "cgroup.controllers")); err == nil && !info.IsDir() {
+		return CgroupV2, cgroupV2Root
+	}
+
+	if info, err := os.Stat(cgroupV1MemoryRoot); err == nil && info.IsDir() {
+		return CgroupV1, cgroupV1MemoryRoot
+	}
+
+	return CgroupUnsupported, ""
+}
+
+// Enabled reports whether cgroup metering is available on this host.
+//
+// This currently always returns false: metering only measures anything if
+// the job's actual worker process has its PID written into
+// "<dir>/cgroup.procs", and nothing in this codebase does that today -
+// in-process ONNX inference never leaves this process to join a slice,
+// and the HTTP/gRPC ML backends run on a separate host this process has no
+// cgroupfs access to. Enabling this without a real out-of-process worker
+// to place into the slice would just create and tear down an empty
+// directory per frame and always read back zeroed usage. Flip this back to
+// `rm.version != CgroupUnsupported` once such a worker exists.
+func (rm *ResourceMonitor) Enabled() bool {
+	return false
+}
+
+// StartJob creates (or reuses) a dedicated cgroup slice for jobID and
+// returns the directory the caller should place the subprocess into, e.g.
+// by writing its PID to "<dir>/cgroup.procs" after spawning it.
+func (rm *ResourceMonitor) StartJob(jobID string) (string, error) {
+	if !rm.Enabled() {
+		return "", fmt.Errorf("cgroup metering not supported on this host")
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	dir := filepath.Join(rm.root, fmt.Sprintf("motorcycle-cv-%s", jobID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cgroup slice: %w", err)
+	}
+
+	rm.slices[jobID] = &cgroupSlice{name: jobID, dir: dir}
+	return dir, nil
+}
+
+// FinishJob reads back accumulated resource usage for jobID and removes
+// its cgroup slice. Safe to call even if StartJob failed or was never
+// called (returns a zero-value usage in that case).
+func (rm *ResourceMonitor) FinishJob(jobID string) *ResourceUsage {
+	rm.mutex.Lock()
+	slice, ok := rm.slices[jobID]
+	delete(rm.slices, jobID)
+	rm.mutex.Unlock()
+
+	if !ok {
+		return &ResourceUsage{}
+	}
+
+	usage := &ResourceUsage{}
+
+	switch rm.version {
+	case CgroupV2:
+		rm.readV2(slice.dir, usage)
+	case CgroupV1:
+		rm.readV1(slice.dir, usage)
+	}
+
+	if usage.CPUUsageUsec > 0 {
+		usage.ThrottleRatio = float64(usage.CPUThrottledUsec) / float64(usage.CPUUsageUsec+usage.CPUThrottledUsec)
+	}
+
+	rm.sampled.Add(1)
+	if usage.ThrottleRatio > 0 {
+		rm.throttled.Add(1)
+	}
+
+	if err := os.Remove(slice.dir); err != nil {
+		rm.logger.Debug("Failed to remove cgroup slice", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	return usage
+}
+
+func (rm *ResourceMonitor) readV2(dir string, usage *ResourceUsage) {
+	if v, err := readIntFile(filepath.Join(dir, "memory.peak")); err == nil {
+		usage.PeakMemoryBytes = v
+	}
+
+	if v, err := readIntFile(filepath.Join(dir, "pids.current")); err == nil {
+		usage.PIDsCurrent = v
+	}
+
+	stats, err := readKeyedStatsFile(filepath.Join(dir, "cpu.stat"))
+	if err == nil {
+		usage.CPUUsageUsec = stats["usage_usec"]
+		usage.CPUThrottledUsec = stats["throttled_usec"]
+	}
+}
+
+func (rm *ResourceMonitor) readV1(dir string, usage *ResourceUsage) {
+	memDir := filepath.Join(cgroupV1MemoryRoot, filepath.Base(dir))
+	if v, err := readIntFile(filepath.Join(memDir, "memory.max_usage_in_bytes")); err == nil {
+		usage.PeakMemoryBytes = v
+	}
+
+	cpuDir := filepath.Join(cgroupV1CPURoot, filepath.Base(dir))
+	stats, err := readKeyedStatsFile(filepath.Join(cpuDir, "cpu.stat"))
+	if err == nil {
+		usage.CPUThrottledUsec = stats["throttled_time"] / 1000
+	}
+
+	if v, err := readIntFile(filepath.Join(cpuDir, "cpuacct.usage")); err == nil {
+		usage.CPUUsageUsec = v / 1000 // ns -> us
+	}
+}
+
+// ThrottleRatio reports the fraction of recently-finished jobs that hit
+// CPU throttling, used by admission control to back off when the host is
+// oversubscribed.
+func (rm *ResourceMonitor) ThrottleRatio() float64 {
+	sampled := rm.sampled.Load()
+	if sampled == 0 {
+		return 0
+	}
+	return float64(rm.throttled.Load()) / float64(sampled)
+}
+
+func readIntFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readKeyedStatsFile(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			stats[fields[0]] = v
+		}
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// clientLatencyWindow bounds how many recent per-client latencies
+// ClientStatsObserver keeps for percentile computation, mirroring
+// statsReporter's latencyHistoryCap but scoped much smaller since it's
+// multiplied by however many distinct clients connect.
+const clientLatencyWindow = 256
+
+// ClientStatsObserver is a PipelineObserver that keeps rolling per-client
+// stats - frames/sec, latency percentiles, drop rate, ML retry count - for
+// the GET /debug/stats endpoint. It follows the same ring-buffer-plus-
+// percentileOf approach as statsReporter (see reporter.go), just keyed per
+// client instead of process-wide.
+type ClientStatsObserver struct {
+	mutex   sync.RWMutex
+	clients map[string]*clientStats
+}
+
+type clientStats struct {
+	frameCount   int64
+	droppedCount int64
+	retryCount   int64
+	errorCount   int64
+	firstSeen    time.Time
+	latencies    []float64
+	cursor       int
+}
+
+// ClientSnapshot is a point-in-time view of one client's stats.
+type ClientSnapshot struct {
+	ClientID     string  `json:"client_id"`
+	FrameCount   int64   `json:"frame_count"`
+	DroppedCount int64   `json:"dropped_count"`
+	RetryCount   int64   `json:"retry_count"`
+	ErrorCount   int64   `json:"error_count"`
+	FramesPerSec float64 `json:"frames_per_sec"`
+	DropRate     float64 `json:"drop_rate"`
+	P50LatencyMs float64 `json:"p50_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+}
+
+func NewClientStatsObserver() *ClientStatsObserver {
+	return &ClientStatsObserver{clients: make(map[string]*clientStats)}
+}
+
+// ObserveFrame implements PipelineObserver. "analyze_frame" events only
+// contribute retry counts and "dropped" events only the drop count;
+// "process_frame" events drive everything else,
+// since that's the stage with a ClientID, duration, and terminal error that
+// actually reached or failed to reach a result.
+func (o *ClientStatsObserver) ObserveFrame(event FrameEvent) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	cs := o.clientLocked(event.ClientID)
+
+	switch event.Stage {
+	case "analyze_frame":
+		cs.retryCount += int64(event.Retries)
+	case "dropped":
+		cs.droppedCount++
+	case "process_frame":
+		if cs.firstSeen.IsZero() {
+			cs.firstSeen = event.Timestamp
+		}
+		cs.frameCount++
+		if event.Err != nil {
+			cs.errorCount++
+		}
+		cs.recordLatency(float64(event.Duration.Milliseconds()))
+	}
+}
+
+func (o *ClientStatsObserver) clientLocked(clientID string) *clientStats {
+	cs, ok := o.clients[clientID]
+	if !ok {
+		cs = &clientStats{latencies: make([]float64, 0, clientLatencyWindow)}
+		o.clients[clientID] = cs
+	}
+	return cs
+}
+
+func (cs *clientStats) recordLatency(ms float64) {
+	if len(cs.latencies) < clientLatencyWindow {
+		cs.latencies = append(cs.latencies, ms)
+	} else {
+		cs.latencies[cs.cursor] = ms
+		cs.cursor = (cs.cursor + 1) % clientLatencyWindow
+	}
+}
+
+// Snapshot returns a point-in-time view of every client seen so far.
+func (o *ClientStatsObserver) Snapshot() []ClientSnapshot {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	snapshots := make([]ClientSnapshot, 0, len(o.clients))
+	for clientID, cs := range o.clients {
+		window := time.Since(cs.firstSeen).Seconds()
+		var fps float64
+		if window > 0 {
+			fps = float64(cs.frameCount) / window
+		}
+
+		total := cs.frameCount + cs.droppedCount
+		var dropRate float64
+		if total > 0 {
+			dropRate = float64(cs.droppedCount) / float64(total)
+		}
+
+		sorted := append([]float64(nil), cs.latencies...)
+		sort.Float64s(sorted)
+
+		snapshots = append(snapshots, ClientSnapshot{
+			ClientID:     clientID,
+			FrameCount:   cs.frameCount,
+			DroppedCount: cs.droppedCount,
+			RetryCount:   cs.retryCount,
+			ErrorCount:   cs.errorCount,
+			FramesPerSec: fps,
+			DropRate:     dropRate,
+			P50LatencyMs: percentileOf(sorted, 0.50),
+			P95LatencyMs: percentileOf(sorted, 0.95),
+			P99LatencyMs: percentileOf(sorted, 0.99),
+		})
+	}
+
+	return snapshots
+}
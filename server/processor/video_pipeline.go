@@ -0,0 +1,263 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/san-kum/motorcycle-cv/server/models"
+	"go.uber.org/zap"
+)
+
+// processVideo demuxes videoData with ffmpeg at the configured FPS (or
+// keyframes only), streams the resulting frames into the existing
+// ProcessingQueue as FrameRequests tagged with job.ID, and aggregates the
+// per-frame AnalysisResults into a RidingSession once extraction and
+// analysis complete. It honors ctx for cancellation and keeps job.Results
+// populated incrementally so GetJobStatus can return partial results
+// while the job is still running.
+func (fp *FrameProcessor) processVideo(ctx context.Context, job *VideoJob, videoData []byte, clientID string) {
+	fp.logger.Info("Video processing started", zap.String("job_id", job.ID))
+
+	workDir, err := os.MkdirTemp("", "motorcycle-cv-video-"+job.ID)
+	if err != nil {
+		fp.failVideoJob(job, fmt.Errorf("failed to create work dir: %w", err))
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, job.Filename)
+	if err := os.WriteFile(inputPath, videoData, 0644); err != nil {
+		fp.failVideoJob(job, fmt.Errorf("failed to write upload to disk: %w", err))
+		return
+	}
+
+	framePattern := filepath.Join(workDir, "frame-%06d.jpg")
+	if err := fp.extractFrames(ctx, inputPath, framePattern); err != nil {
+		if ctx.Err() != nil {
+			fp.mutex.Lock()
+			job.Status = "cancelled"
+			fp.mutex.Unlock()
+			return
+		}
+		fp.failVideoJob(job, fmt.Errorf("frame extraction failed: %w", err))
+		return
+	}
+
+	frames, err := filepath.Glob(filepath.Join(workDir, "frame-*.jpg"))
+	if err != nil || len(frames) == 0 {
+		fp.failVideoJob(job, fmt.Errorf("no frames extracted from video"))
+		return
+	}
+	sort.Strings(frames)
+
+	fp.mutex.Lock()
+	job.TotalFrames = len(frames)
+	fp.mutex.Unlock()
+
+	startTime := time.Now()
+	var results []models.AnalysisResult
+
+	// frameResults stays index-aligned with frames (nil where a frame was
+	// unreadable or failed analysis), unlike results above which only holds
+	// successes - manifest.go indexes bounding boxes and VTT cues by frame
+	// position, and results skips entries on failure, so passing results
+	// there would silently attribute one frame's overlay to the next.
+	frameResults := make([]*models.AnalysisResult, len(frames))
+
+	for i, framePath := range frames {
+		select {
+		case <-ctx.Done():
+			fp.mutex.Lock()
+			job.Status = "cancelled"
+			fp.mutex.Unlock()
+			return
+		default:
+		}
+
+		imageData, err := os.ReadFile(framePath)
+		if err != nil {
+			fp.logger.Warn("Failed to read extracted frame",
+				zap.String("job_id", job.ID), zap.String("path", framePath), zap.Error(err))
+			continue
+		}
+
+		request := &models.FrameRequest{
+			ImageData: imageData,
+			Timestamp: time.Now().UnixMilli(),
+			ClientID:  clientID,
+			Metadata: map[string]any{
+				"video_job_id": job.ID,
+				"frame_index":  i,
+			},
+		}
+
+		result, err := fp.ProcessFrame(ctx, request)
+		if err != nil {
+			fp.logger.Warn("Frame analysis failed during video processing",
+				zap.String("job_id", job.ID), zap.Int("frame", i), zap.Error(err))
+		} else if result != nil {
+			results = append(results, *result)
+			frameResults[i] = result
+		}
+
+		fp.mutex.Lock()
+		job.Progress = float64(i+1) / float64(len(frames)) * 100.0
+		job.Results = append([]models.AnalysisResult{}, results...)
+		fp.mutex.Unlock()
+	}
+
+	session := fp.buildRidingSession(job, clientID, results, startTime, time.Now())
+
+	if err := fp.buildMediaSegments(job, frames, frameResults); err != nil {
+		fp.logger.Warn("Failed to build DASH/HLS media segments, job results still available",
+			zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	fp.mutex.Lock()
+	job.Status = "completed"
+	job.Progress = 100.0
+	job.Session = session
+	fp.mutex.Unlock()
+
+	fp.logger.Info("Video processing completed",
+		zap.String("job_id", job.ID), zap.Int("frames", len(frames)))
+}
+
+func (fp *FrameProcessor) failVideoJob(job *VideoJob, err error) {
+	fp.logger.Error("Video processing failed", zap.String("job_id", job.ID), zap.Error(err))
+	fp.mutex.Lock()
+	job.Status = "failed"
+	job.Error = err.Error()
+	fp.mutex.Unlock()
+}
+
+// extractFrames shells out to ffmpeg to demux videoPath into JPEG frames
+// at framePattern (an ffmpeg printf-style pattern), at the configured FPS
+// or keyframes-only, and returns once ffmpeg exits or ctx is cancelled.
+func (fp *FrameProcessor) extractFrames(ctx context.Context, videoPath, framePattern string) error {
+	args := []string{"-y", "-i", videoPath}
+
+	if fp.config.VideoKeyframesOnly {
+		args = append(args, "-vf", "select='eq(pict_type,I)'", "-vsync", "vfr")
+	} else {
+		fps := fp.config.VideoFPS
+		if fps <= 0 {
+			fps = 2.0
+		}
+		args = append(args, "-vf", fmt.Sprintf("fps=%g", fps))
+	}
+
+	args = append(args, "-q:v", "2", framePattern)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, string(output))
+	}
+	return nil
+}
+
+// buildRidingSession aggregates per-frame analysis results into a
+// RidingSession summary, populating averages, highlights, and a coarse
+// session summary for the full clip.
+func (fp *FrameProcessor) buildRidingSession(job *VideoJob, clientID string, results []models.AnalysisResult, start, end time.Time) *models.RidingSession {
+	session := &models.RidingSession{
+		ID:          job.ID,
+		ClientID:    clientID,
+		StartTime:   start,
+		EndTime:     end,
+		TotalFrames: len(results),
+	}
+
+	if len(results) == 0 {
+		session.Summary = models.SessionSummary{
+			OverallGrade: "N/A",
+			Duration:     end.Sub(start).Minutes(),
+		}
+		return session
+	}
+
+	var overallSum, postureSum, laneSum, speedSum int
+	var highlights []models.Highlight
+
+	for _, r := range results {
+		overallSum += r.OverallScore
+		postureSum += r.PostureScore
+		laneSum += r.LaneScore
+		speedSum += r.SpeedScore
+
+		if r.OverallScore >= 90 {
+			highlights = append(highlights, models.Highlight{
+				Timestamp:   r.Timestamp,
+				Type:        "strong_riding",
+				Description: "Excellent overall score",
+				Score:       r.OverallScore,
+				Category:    "performance",
+			})
+		} else if r.OverallScore <= 40 {
+			highlights = append(highlights, models.Highlight{
+				Timestamp:   r.Timestamp,
+				Type:        "safety_concern",
+				Description: "Low overall score - review this segment",
+				Score:       r.OverallScore,
+				Category:    "safety",
+			})
+		}
+	}
+
+	n := len(results)
+	session.AvgOverallScore = overallSum / n
+	session.AvgPostureScore = postureSum / n
+	session.AvgLaneScore = laneSum / n
+	session.AvgSpeedScore = speedSum / n
+	session.Highlights = highlights
+	session.Summary = summarizeSession(session)
+
+	return session
+}
+
+func summarizeSession(session *models.RidingSession) models.SessionSummary {
+	grade := "C"
+	switch {
+	case session.AvgOverallScore >= 90:
+		grade = "A"
+	case session.AvgOverallScore >= 80:
+		grade = "B"
+	case session.AvgOverallScore >= 70:
+		grade = "C"
+	case session.AvgOverallScore >= 60:
+		grade = "D"
+	default:
+		grade = "F"
+	}
+
+	var strengths, improvements []string
+	if session.AvgPostureScore >= 80 {
+		strengths = append(strengths, "posture")
+	} else {
+		improvements = append(improvements, "posture")
+	}
+	if session.AvgLaneScore >= 80 {
+		strengths = append(strengths, "lane discipline")
+	} else {
+		improvements = append(improvements, "lane discipline")
+	}
+	if session.AvgSpeedScore >= 80 {
+		strengths = append(strengths, "speed management")
+	} else {
+		improvements = append(improvements, "speed management")
+	}
+
+	return models.SessionSummary{
+		OverallGrade:     grade,
+		StrengthAreas:    strengths,
+		ImprovementAreas: improvements,
+		SafetyScore:      session.AvgOverallScore,
+		Duration:         session.EndTime.Sub(session.StartTime).Minutes(),
+	}
+}